@@ -0,0 +1,133 @@
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+// the codes are part of the api, the clients are expected to check them rather
+// than the messages so they must never change once they are out
+const (
+	CodeValidation      = "validation"
+	CodeUnauthorized    = "unauthorized"
+	CodeForbidden       = "forbidden"
+	CodeNotFound        = "not_found"
+	CodeConflict        = "conflict"
+	CodeTooManyRequests = "too_many_requests"
+	CodePaymentDeclined = "payment_declined"
+	CodePaymentFailed   = "payment_failed"
+	CodeInternal        = "internal"
+)
+
+// the error the handlers hand over to gin with c.Error, the ErrorHandler
+// middleware turns it into a problem details response (RFC 7807)
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	// the fields of the input that are wrong, if the error is about any
+	Fields []FieldError
+	// the extra members of the problem details, e.g. how many items are left in stock
+	Extensions map[string]interface{}
+	// the underlying error, it is only logged and never sent to the client
+	Err error
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+
+	return e.Code + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) WithField(field, code, message string) *Error {
+	e.Fields = append(e.Fields, FieldError{Field: field, Code: code, Message: message})
+	return e
+}
+
+func (e *Error) WithExtension(key string, value interface{}) *Error {
+	if e.Extensions == nil {
+		e.Extensions = make(map[string]interface{})
+	}
+	e.Extensions[key] = value
+	return e
+}
+
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func Validation(message string) *Error {
+	return New(http.StatusBadRequest, CodeValidation, message)
+}
+
+// for the errors of binding the requests (and parsing the params), the failing
+// fields are listed if the error tells which ones they are
+func InvalidInput(err error) *Error {
+	e := fromBindingError(err)
+	e.Err = err
+	return e
+}
+
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, message)
+}
+
+func Conflict(message string) *Error {
+	return New(http.StatusConflict, CodeConflict, message)
+}
+
+func TooManyRequests(message string) *Error {
+	return New(http.StatusTooManyRequests, CodeTooManyRequests, message)
+}
+
+// wraps an error nobody expected, whatever it says stays in the logs
+func Internal(err error) *Error {
+	e := New(http.StatusInternalServerError, CodeInternal, "Something went wrong, please try again later")
+	e.Err = err
+	return e
+}
+
+// turns any error into an app error, the ones that are not app errors already are
+// either translated (e.g. a record that is not found or a unique violation) or
+// treated as internal errors
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		e := NotFound("Resource not found")
+		e.Err = err
+		return e
+	}
+
+	if pgErr := fromPgError(err); pgErr != nil {
+		return pgErr
+	}
+
+	return Internal(err)
+}
@@ -0,0 +1,38 @@
+package apperrors
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgconn"
+)
+
+const pgUniqueViolation = "23505"
+
+// the detail of a unique violation looks like "Key (email)=(someone@example.com)
+// already exists.", only the column names are taken since the value might be
+// someone else's
+var pgUniqueKeyRegex = regexp.MustCompile(`^Key \(([^)]+)\)=`)
+
+func fromPgError(err error) *Error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != pgUniqueViolation {
+		return nil
+	}
+
+	e := Conflict("Resource already exists")
+	e.Err = err
+
+	match := pgUniqueKeyRegex.FindStringSubmatch(pgErr.Detail)
+	if match == nil {
+		return e
+	}
+
+	for _, field := range strings.Split(match[1], ",") {
+		field = strings.TrimSpace(field)
+		e.WithField(field, "unique", field+" is already taken")
+	}
+
+	return e
+}
@@ -0,0 +1,117 @@
+package apperrors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// lists every field that has failed the validation, the code of each field is
+// the rule it has failed (e.g. required, email, max)
+func fromBindingError(err error) *Error {
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+
+	switch {
+	case errors.As(err, &validationErrs):
+		e := Validation("The request is invalid")
+		for _, fieldErr := range validationErrs {
+			e.WithField(fieldErr.Field(), fieldErr.Tag(), validationMessage(fieldErr))
+		}
+		return e
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		e := Validation("The request is invalid")
+		return e.WithField(typeErr.Field, "type", fmt.Sprintf("%s must be a %s", typeErr.Field, jsonTypeName(typeErr.Type)))
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return Validation("The request body is not valid JSON")
+	case errors.Is(err, io.EOF):
+		return Validation("The request body is empty")
+	default:
+		// whatever else it says (e.g. an id that can't be parsed) is only logged
+		return Validation("The request is invalid")
+	}
+}
+
+func validationMessage(fieldErr validator.FieldError) string {
+	field := fieldErr.Field()
+
+	switch fieldErr.Tag() {
+	case "required":
+		return field + " is required"
+	case "required_without":
+		return fmt.Sprintf("%s is required when %s is not set", field, jsonName(fieldErr.Param()))
+	case "email":
+		return field + " must be a valid email address"
+	case "min", "max", "len":
+		return lengthMessage(fieldErr)
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s", field, strings.ReplaceAll(fieldErr.Param(), " ", ", "))
+	case "username":
+		return field + " must be 3 to 24 letters, numbers, dots, dashes or underscores"
+	case "phone":
+		return field + " must be a valid phone number"
+	case "zip_code":
+		return field + " is not a valid zip code for the country"
+	case "iso3166_1_alpha2":
+		return field + " must be a two-letter country code (ISO 3166-1 alpha-2)"
+	default:
+		return fmt.Sprintf("%s failed the %s rule", field, fieldErr.Tag())
+	}
+}
+
+// the lengths of the strings and the slices are counted, the numbers are compared
+func lengthMessage(fieldErr validator.FieldError) string {
+	unit := ""
+	switch fieldErr.Kind() {
+	case reflect.String:
+		unit = " characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		unit = " items"
+	}
+
+	var format string
+	switch fieldErr.Tag() {
+	case "min":
+		format = "%s must be at least %s%s"
+	case "max":
+		format = "%s must be at most %s%s"
+	default:
+		format = "%s must be exactly %s%s"
+	}
+
+	return fmt.Sprintf(format, fieldErr.Field(), fieldErr.Param(), unit)
+}
+
+// the params of the cross-field rules are the go names of the fields
+func jsonName(goName string) string {
+	var b strings.Builder
+	for i, r := range goName {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+
+	return strings.ToLower(b.String())
+}
+
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "list"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	default:
+		return "number"
+	}
+}
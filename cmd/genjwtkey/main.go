@@ -0,0 +1,65 @@
+// genjwtkey generates a new key for signing the tokens and writes it to the keys
+// directory as <kid>.pem, e.g.
+//
+//	go run ./cmd/genjwtkey -alg EdDSA -dir keys
+//
+// the printed kid is what JWT_SIGNING_KEY_ID has to be set to in order to sign
+// the tokens with the new key
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func main() {
+	alg := flag.String("alg", "RS256", "signing algorithm: RS256 or EdDSA")
+	dir := flag.String("dir", "keys", "directory the key is written to")
+	kid := flag.String("kid", time.Now().UTC().Format("20060102150405"), "key id")
+	flag.Parse()
+
+	var privateKey interface{}
+	var err error
+	switch *alg {
+	case "RS256":
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "EdDSA":
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		log.Fatalf("Unsupported algorithm %q", *alg)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.MkdirAll(*dir, 0700); err != nil {
+		log.Fatal(err)
+	}
+
+	path := filepath.Join(*dir, *kid+".pem")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	if err := pem.Encode(file, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Wrote %s, sign the tokens with it by setting JWT_SIGNING_KEY_ID=%s\n", path, *kid)
+}
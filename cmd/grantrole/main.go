@@ -0,0 +1,50 @@
+// grantrole grants a role to a user, it is mostly meant for granting the admin
+// role to the first admin since roles can only be granted by admins otherwise, e.g.
+//
+//	go run ./cmd/grantrole -email admin@example.com -role admin
+//
+// the database is taken from the DATA_SOURCE_NAME env var (or the .env file)
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/laluardian/gin-ecommerce-api/libs"
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/laluardian/gin-ecommerce-api/repositories"
+)
+
+func main() {
+	godotenv.Load()
+
+	email := flag.String("email", "", "email of the user")
+	roleName := flag.String("role", models.RoleAdmin, "name of the role")
+	flag.Parse()
+
+	if *email == "" {
+		log.Fatal("The -email flag is required")
+	}
+
+	db := libs.InitDB(os.Getenv("DATA_SOURCE_NAME"))
+
+	user, err := repositories.NewUserRepository(db).FindByEmail(*email)
+	if err != nil {
+		log.Fatalf("User %s not found", *email)
+	}
+
+	roleRepo := repositories.NewRoleRepository(db)
+	role, err := roleRepo.FindByName(*roleName)
+	if err != nil {
+		log.Fatalf("Role %s not found", *roleName)
+	}
+
+	if err := roleRepo.AddToUser(&role, &user); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Granted the %s role to %s, the new permissions apply to the tokens issued from now on\n", role.Name, user.Email)
+}
@@ -0,0 +1,60 @@
+// signwebhook signs a fake payment webhook event and prints a curl command that
+// sends it to the local webhook receiver, e.g.
+//
+//	go run ./cmd/signwebhook -type payment.captured -reference fake_xxxxxxxx
+//
+// the secret is taken from the PAYMENT_WEBHOOK_SECRET env var (or the .env file)
+// unless it is passed with the -secret flag
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/laluardian/gin-ecommerce-api/payments"
+	"github.com/rs/xid"
+)
+
+func main() {
+	godotenv.Load()
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "4444"
+	}
+
+	secret := flag.String("secret", os.Getenv("PAYMENT_WEBHOOK_SECRET"), "webhook signing secret")
+	url := flag.String("url", "http://localhost:"+port+"/api/webhooks/payments", "webhook receiver url")
+	id := flag.String("id", "evt_"+xid.New().String(), "event id, reuse an id to test replays")
+	eventType := flag.String("type", payments.EventPaymentCaptured, "event type")
+	reference := flag.String("reference", "", "provider reference of the payment")
+	reason := flag.String("reason", "", "failure reason (for payment.failed events)")
+	flag.Parse()
+
+	if *secret == "" {
+		log.Fatal("A secret is required, set PAYMENT_WEBHOOK_SECRET or pass -secret")
+	}
+
+	body, err := json.Marshal(payments.WebhookEvent{
+		ID:        *id,
+		Type:      *eventType,
+		Reference: *reference,
+		Reason:    *reason,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	header := payments.SignWebhook([]byte(*secret), body, time.Now())
+
+	fmt.Printf("curl -X POST %s \\\n", *url)
+	fmt.Printf("  -H 'Content-Type: application/json' \\\n")
+	fmt.Printf("  -H '%s: %s' \\\n", payments.TimestampHeader, header.Get(payments.TimestampHeader))
+	fmt.Printf("  -H '%s: %s' \\\n", payments.SignatureHeader, header.Get(payments.SignatureHeader))
+	fmt.Printf("  -d '%s'\n", body)
+}
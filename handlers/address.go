@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/laluardian/gin-ecommerce-api/apperrors"
 	"github.com/laluardian/gin-ecommerce-api/libs"
 	"github.com/laluardian/gin-ecommerce-api/models"
 	"github.com/laluardian/gin-ecommerce-api/repositories"
@@ -33,25 +35,20 @@ func (ah *addressHandler) AddAddress(c *gin.Context) {
 	userId, _ := xid.FromString(c.Param("userId"))
 	payload := libs.CheckUserId(c, userId)
 	if payload == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Unauthorized",
-		})
+		c.Error(apperrors.Unauthorized("Unauthorized"))
 		return
 	}
 
-	var addressInput models.Address
+	var addressInput models.AddressDto
 	if err := c.ShouldBindJSON(&addressInput); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(apperrors.InvalidInput(err))
 		return
 	}
 
-	addressInput.UserID = userId
-	if user := ah.repo.Create(&addressInput); user != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"user": user,
-		})
+	address := newAddress(&addressInput)
+	address.UserID = userId
+	if err := ah.repo.Create(&address); err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -64,22 +61,27 @@ func (ah *addressHandler) GetUserAddresses(c *gin.Context) {
 	userId, _ := xid.FromString(c.Param("userId"))
 	payload := libs.CheckUserId(c, userId)
 	if payload == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Unauthorized",
-		})
+		c.Error(apperrors.Unauthorized("Unauthorized"))
 		return
 	}
 
-	addresses, err := ah.repo.FindByUser(userId)
+	pagination, err := libs.NewPagination(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(apperrors.InvalidInput(err))
 		return
 	}
 
+	addresses, err := ah.repo.FindByUser(userId, pagination)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	pagination.SetLinkHeader(c)
+
 	c.JSON(http.StatusOK, gin.H{
-		"addresses": addresses,
+		"addresses":  models.NewAddressDetails(addresses),
+		"pagination": pagination,
 	})
 }
 
@@ -87,23 +89,19 @@ func (ah *addressHandler) GetAddress(c *gin.Context) {
 	userId, _ := xid.FromString(c.Param("userId"))
 	payload := libs.CheckUserId(c, userId)
 	if payload == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Unauthorized",
-		})
+		c.Error(apperrors.Unauthorized("Unauthorized"))
 		return
 	}
 
 	addressId, _ := xid.FromString(c.Param("addressId"))
 	address, err := ah.repo.FindByIds(userId, addressId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"address": address,
+		"address": models.NewAddressDetail(&address),
 	})
 }
 
@@ -111,27 +109,21 @@ func (ah *addressHandler) UpdateAddress(c *gin.Context) {
 	userId, _ := xid.FromString(c.Param("userId"))
 	payload := libs.CheckUserId(c, userId)
 	if payload == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Unauthorized",
-		})
+		c.Error(apperrors.Unauthorized("Unauthorized"))
 		return
 	}
 
-	var addressInput models.Address
+	var addressInput models.AddressDto
 	if err := c.ShouldBindJSON(&addressInput); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(apperrors.InvalidInput(err))
 		return
 	}
 
-	addressId, _ := xid.FromString(c.Param("addressId"))
-	addressInput.ID = addressId
-	addressInput.UserID = userId
-	if err := ah.repo.Update(&addressInput); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+	address := newAddress(&addressInput)
+	address.ID, _ = xid.FromString(c.Param("addressId"))
+	address.UserID = userId
+	if err := ah.repo.Update(&address); err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -144,17 +136,13 @@ func (ah *addressHandler) DeleteAddress(c *gin.Context) {
 	userId, _ := xid.FromString(c.Param("userId"))
 	payload := libs.CheckUserId(c, userId)
 	if payload == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Unauthorized",
-		})
+		c.Error(apperrors.Unauthorized("Unauthorized"))
 		return
 	}
 
 	addressId, _ := xid.FromString(c.Param("addressId"))
 	if err := ah.repo.Delete(addressId); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(err)
 		return
 	}
 
@@ -162,3 +150,17 @@ func (ah *addressHandler) DeleteAddress(c *gin.Context) {
 		"message": "Address successfully deleted",
 	})
 }
+
+// the zip codes are stored in upper case whatever case they have been sent in
+func newAddress(addressInput *models.AddressDto) models.Address {
+	return models.Address{
+		AddressName:         addressInput.AddressName,
+		ReceiverName:        addressInput.ReceiverName,
+		ReceiverPhoneNumber: addressInput.ReceiverPhoneNumber,
+		StreetAddress:       addressInput.StreetAddress,
+		City:                addressInput.City,
+		Province:            addressInput.Province,
+		Country:             addressInput.Country,
+		ZipCode:             strings.ToUpper(addressInput.ZipCode),
+	}
+}
@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laluardian/gin-ecommerce-api/apperrors"
+	"github.com/laluardian/gin-ecommerce-api/libs"
+	"github.com/laluardian/gin-ecommerce-api/mailer"
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/laluardian/gin-ecommerce-api/repositories"
+	"github.com/laluardian/gin-ecommerce-api/revocation"
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+// lets the admins act on any account, unlike the user handler nothing here
+// checks that the user is the one that is signed in, the routes are guarded by
+// the permissions instead... every action that changes something is audited
+type AdminUserHandler interface {
+	GetUsers(c *gin.Context)
+	GetUser(c *gin.Context)
+	SuspendUser(c *gin.Context)
+	UnsuspendUser(c *gin.Context)
+	ForcePasswordReset(c *gin.Context)
+	GrantRole(c *gin.Context)
+	RevokeRole(c *gin.Context)
+	ImpersonateUser(c *gin.Context)
+	GetAuditLogs(c *gin.Context)
+}
+
+type adminUserHandler struct {
+	db           *gorm.DB
+	repo         repositories.UserRepository
+	roleRepo     repositories.RoleRepository
+	auditLogRepo repositories.AuditLogRepository
+	revocations  revocation.RevocationStore
+	mails        *userMailer
+}
+
+func NewAdminUserHandler(db *gorm.DB, revocations revocation.RevocationStore, mailer mailer.Mailer) AdminUserHandler {
+	return &adminUserHandler{
+		db,
+		repositories.NewUserRepository(db),
+		repositories.NewRoleRepository(db),
+		repositories.NewAuditLogRepository(db),
+		revocations,
+		newUserMailer(db, mailer),
+	}
+}
+
+// does the action and records it in the same transaction, so an action is never
+// done without being audited... fn gets the transaction to do the action with
+func (ah *adminUserHandler) audited(c *gin.Context, action string, target *models.User, details gin.H, fn func(tx *gorm.DB) error) error {
+	payload := c.MustGet(libs.JwtPayloadKey).(*libs.JwtPayload)
+
+	auditLog := models.AuditLog{
+		ActorID:    payload.Sub,
+		Action:     action,
+		TargetType: "user",
+		TargetID:   target.ID,
+		IPAddress:  c.ClientIP(),
+	}
+	if details != nil {
+		detailsJson, _ := json.Marshal(details)
+		auditLog.Details = string(detailsJson)
+	}
+
+	return ah.db.Transaction(func(tx *gorm.DB) error {
+		if fn != nil {
+			if err := fn(tx); err != nil {
+				return err
+			}
+		}
+
+		return repositories.NewAuditLogRepository(tx).Create(&auditLog)
+	})
+}
+
+// finds the user of the userId param, the response is sent when it is not found
+func (ah *adminUserHandler) findUser(c *gin.Context) (models.User, bool) {
+	userId, _ := xid.FromString(c.Param("userId"))
+	user, err := ah.repo.FindById(userId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.NotFound("User not found"))
+			return user, false
+		}
+
+		c.Error(err)
+		return user, false
+	}
+
+	return user, true
+}
+
+func (ah *adminUserHandler) GetUsers(c *gin.Context) {
+	pagination, err := libs.NewPagination(c)
+	if err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	users, err := ah.repo.FindMany(pagination)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	pagination.SetLinkHeader(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"users":      models.NewAdminUsers(users),
+		"pagination": pagination,
+	})
+}
+
+func (ah *adminUserHandler) GetUser(c *gin.Context) {
+	user, ok := ah.findUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": models.NewAdminUser(&user),
+	})
+}
+
+// a suspended user is signed out everywhere and can't sign in again until the
+// suspension is lifted
+func (ah *adminUserHandler) SuspendUser(c *gin.Context) {
+	user, ok := ah.findUser(c)
+	if !ok {
+		return
+	}
+
+	var suspendInput models.SuspendUserDto
+	if err := c.ShouldBindJSON(&suspendInput); err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	if user.SuspendedAt != nil {
+		c.Error(apperrors.Conflict("User is already suspended"))
+		return
+	}
+
+	err := ah.audited(c, models.AuditActionUserSuspend, &user, gin.H{"reason": suspendInput.Reason}, func(tx *gorm.DB) error {
+		return repositories.NewUserRepository(tx).SetSuspended(&user, true, suspendInput.Reason)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := ah.revocations.RevokeUser(user.ID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User successfully suspended",
+	})
+}
+
+func (ah *adminUserHandler) UnsuspendUser(c *gin.Context) {
+	user, ok := ah.findUser(c)
+	if !ok {
+		return
+	}
+
+	if user.SuspendedAt == nil {
+		c.Error(apperrors.Conflict("User is not suspended"))
+		return
+	}
+
+	err := ah.audited(c, models.AuditActionUserUnsuspend, &user, nil, func(tx *gorm.DB) error {
+		return repositories.NewUserRepository(tx).SetSuspended(&user, false, "")
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User successfully unsuspended",
+	})
+}
+
+// signs the user out everywhere and doesn't let the user sign in again until the
+// password is reset with the token that is sent by email
+func (ah *adminUserHandler) ForcePasswordReset(c *gin.Context) {
+	user, ok := ah.findUser(c)
+	if !ok {
+		return
+	}
+
+	err := ah.audited(c, models.AuditActionUserPasswordReset, &user, nil, func(tx *gorm.DB) error {
+		return repositories.NewUserRepository(tx).SetPasswordResetRequired(&user)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := ah.revocations.RevokeUser(user.ID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := ah.mails.sendPasswordResetEmail(&user); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Password reset successfully forced",
+	})
+}
+
+// the granted permissions apply to the tokens that are issued from now on (the
+// user's access tokens are short-lived so it doesn't take long)
+func (ah *adminUserHandler) GrantRole(c *gin.Context) {
+	user, ok := ah.findUser(c)
+	if !ok {
+		return
+	}
+
+	var roleInput models.UserRoleDto
+	if err := c.ShouldBindJSON(&roleInput); err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	role, err := ah.roleRepo.FindByName(roleInput.Role)
+	if err != nil {
+		c.Error(apperrors.Validation("Role not found"))
+		return
+	}
+
+	err = ah.audited(c, models.AuditActionUserRoleGrant, &user, gin.H{"role": role.Name}, func(tx *gorm.DB) error {
+		return repositories.NewRoleRepository(tx).AddToUser(&role, &user)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Role successfully granted",
+	})
+}
+
+// unlike granting a role, revoking it has to apply right away so the user's
+// tokens (which still carry the role's permissions) are revoked as well
+func (ah *adminUserHandler) RevokeRole(c *gin.Context) {
+	user, ok := ah.findUser(c)
+	if !ok {
+		return
+	}
+
+	role, err := ah.roleRepo.FindByName(c.Param("role"))
+	if err != nil {
+		c.Error(apperrors.NotFound("Role not found"))
+		return
+	}
+
+	err = ah.audited(c, models.AuditActionUserRoleRevoke, &user, gin.H{"role": role.Name}, func(tx *gorm.DB) error {
+		return repositories.NewRoleRepository(tx).RemoveFromUser(&role, &user)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := ah.revocations.RevokeUser(user.ID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Role successfully revoked",
+	})
+}
+
+// issues a short-lived access token for the user (there is no refresh token),
+// the token carries the act claim so everything done with it can be told apart
+func (ah *adminUserHandler) ImpersonateUser(c *gin.Context) {
+	payload := c.MustGet(libs.JwtPayloadKey).(*libs.JwtPayload)
+
+	user, ok := ah.findUser(c)
+	if !ok {
+		return
+	}
+
+	if user.ID == payload.Sub || payload.IsImpersonated() {
+		c.Error(apperrors.Validation("Cannot impersonate this user"))
+		return
+	}
+
+	if user.SuspendedAt != nil {
+		c.Error(apperrors.Conflict("Cannot impersonate a suspended user"))
+		return
+	}
+
+	actor, err := ah.repo.FindById(payload.Sub)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	token, tokenPayload, err := libs.GenerateImpersonationToken(&user, &actor)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// the token is only handed out once its issuance is audited
+	if err := ah.audited(c, models.AuditActionUserImpersonate, &user, gin.H{"jti": tokenPayload.Jti}, nil); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int(libs.ImpersonationTtl.Seconds()),
+		"act":          tokenPayload.Act,
+	})
+}
+
+// the audit logs can be narrowed down to a single user with the user_id query
+func (ah *adminUserHandler) GetAuditLogs(c *gin.Context) {
+	pagination, err := libs.NewPagination(c)
+	if err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	var userId xid.ID
+	if c.Query("user_id") != "" {
+		userId, err = xid.FromString(c.Query("user_id"))
+		if err != nil {
+			c.Error(apperrors.Validation("Invalid user_id"))
+			return
+		}
+	}
+
+	auditLogs, err := ah.auditLogRepo.FindMany(userId, pagination)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	pagination.SetLinkHeader(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"audit_logs": models.NewAuditLogDetails(auditLogs),
+		"pagination": pagination,
+	})
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laluardian/gin-ecommerce-api/apperrors"
+	"github.com/laluardian/gin-ecommerce-api/libs"
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/laluardian/gin-ecommerce-api/repositories"
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+type CartHandler interface {
+	GetCart(c *gin.Context)
+	AddCartItem(c *gin.Context)
+	UpdateCartItem(c *gin.Context)
+	RemoveCartItem(c *gin.Context)
+	ClearCart(c *gin.Context)
+}
+
+type cartHandler struct {
+	repo        repositories.CartRepository
+	productRepo repositories.ProductRepository
+}
+
+func NewCartHandler(db *gorm.DB) CartHandler {
+	return &cartHandler{
+		repositories.NewCartRepository(db),
+		repositories.NewProductRepository(db),
+	}
+}
+
+func (ch *cartHandler) GetCart(c *gin.Context) {
+	userId, _ := xid.FromString(c.Param("userId"))
+	payload := libs.CheckUserId(c, userId)
+	if payload == nil {
+		c.Error(apperrors.Unauthorized("Unauthorized"))
+		return
+	}
+
+	cart, err := ch.repo.FindOrCreateByUser(userId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"cart": models.NewCartSummary(&cart),
+	})
+}
+
+func (ch *cartHandler) AddCartItem(c *gin.Context) {
+	userId, _ := xid.FromString(c.Param("userId"))
+	payload := libs.CheckUserId(c, userId)
+	if payload == nil {
+		c.Error(apperrors.Unauthorized("Unauthorized"))
+		return
+	}
+
+	var itemInput models.CartItemDto
+	if err := c.ShouldBindJSON(&itemInput); err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	cart, err := ch.repo.FindOrCreateByUser(userId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	product, ok := ch.findProduct(c, itemInput.ProductID)
+	if !ok {
+		return
+	}
+
+	if itemInput.Quantity > product.Quantity {
+		c.Error(insufficientStock(product))
+		return
+	}
+
+	// adding a product that is already in the cart increases its quantity, up to
+	// the same limit a single item has and no further than the stock goes
+	limit := product.Quantity
+	if limit > models.MaxItemQuantity {
+		limit = models.MaxItemQuantity
+	}
+
+	item := models.CartItem{
+		Quantity:  itemInput.Quantity,
+		CartID:    cart.ID,
+		ProductID: product.ID,
+	}
+	added, err := ch.repo.AddItem(&item, limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// the item that is already in the cart only tells which limit was hit
+	if !added {
+		item, err := ch.repo.FindItem(cart.ID, product.ID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		if item.Quantity > models.MaxItemQuantity-itemInput.Quantity {
+			c.Error(apperrors.Validation("Too many units of the product in the cart").
+				WithField("quantity", "max", fmt.Sprintf("quantity must be at most %d in total", models.MaxItemQuantity)))
+			return
+		}
+		c.Error(insufficientStock(product))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Product successfully added to cart",
+	})
+}
+
+func (ch *cartHandler) UpdateCartItem(c *gin.Context) {
+	userId, _ := xid.FromString(c.Param("userId"))
+	payload := libs.CheckUserId(c, userId)
+	if payload == nil {
+		c.Error(apperrors.Unauthorized("Unauthorized"))
+		return
+	}
+
+	var quantityInput models.CartItemQuantityDto
+	if err := c.ShouldBindJSON(&quantityInput); err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	cart, err := ch.repo.FindOrCreateByUser(userId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	productId, _ := xid.FromString(c.Param("productId"))
+	if _, err := ch.repo.FindItem(cart.ID, productId); err != nil {
+		c.Error(apperrors.NotFound("Product is not in the cart"))
+		return
+	}
+
+	ch.saveItem(c, cart.ID, productId, quantityInput.Quantity, "Cart item successfully updated")
+}
+
+func (ch *cartHandler) RemoveCartItem(c *gin.Context) {
+	userId, _ := xid.FromString(c.Param("userId"))
+	payload := libs.CheckUserId(c, userId)
+	if payload == nil {
+		c.Error(apperrors.Unauthorized("Unauthorized"))
+		return
+	}
+
+	cart, err := ch.repo.FindOrCreateByUser(userId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	productId, _ := xid.FromString(c.Param("productId"))
+	if err := ch.repo.RemoveItem(cart.ID, productId); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Product successfully removed from cart",
+	})
+}
+
+func (ch *cartHandler) ClearCart(c *gin.Context) {
+	userId, _ := xid.FromString(c.Param("userId"))
+	payload := libs.CheckUserId(c, userId)
+	if payload == nil {
+		c.Error(apperrors.Unauthorized("Unauthorized"))
+		return
+	}
+
+	cart, err := ch.repo.FindOrCreateByUser(userId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := ch.repo.Clear(cart.ID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Cart successfully cleared",
+	})
+}
+
+// validates the new quantity of a cart item against the current stock of the
+// product before saving it, so the client learns early when something went out
+// of stock instead of only finding out at checkout
+func (ch *cartHandler) findProduct(c *gin.Context, productId xid.ID) (models.Product, bool) {
+	products, err := ch.productRepo.FindByIds([]xid.ID{productId})
+	if err != nil {
+		c.Error(err)
+		return models.Product{}, false
+	}
+
+	if len(products) == 0 {
+		c.Error(apperrors.NotFound("Product not found"))
+		return models.Product{}, false
+	}
+
+	return products[0], true
+}
+
+func insufficientStock(product models.Product) *apperrors.Error {
+	return apperrors.Conflict(fmt.Sprintf("Only %d left in stock", product.Quantity)).
+		WithExtension("available_quantity", product.Quantity)
+}
+
+func (ch *cartHandler) saveItem(c *gin.Context, cartId, productId xid.ID, quantity uint32, message string) {
+	product, ok := ch.findProduct(c, productId)
+	if !ok {
+		return
+	}
+
+	if quantity > product.Quantity {
+		c.Error(insufficientStock(product))
+		return
+	}
+
+	item := models.CartItem{
+		Quantity:  quantity,
+		CartID:    cartId,
+		ProductID: productId,
+	}
+	if err := ch.repo.SaveItem(&item); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+	})
+}
@@ -1,10 +1,10 @@
 package handlers
 
 import (
-	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/laluardian/gin-ecommerce-api/apperrors"
 	"github.com/laluardian/gin-ecommerce-api/libs"
 	"github.com/laluardian/gin-ecommerce-api/models"
 	"github.com/laluardian/gin-ecommerce-api/repositories"
@@ -30,25 +30,18 @@ func NewCategoryHandler(db *gorm.DB) CategoryHandler {
 }
 
 func (ch *categoryHandler) AddCategory(c *gin.Context) {
-	payload := libs.CheckUserRole(c)
-	if payload == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Unauthorized",
-		})
+	var categoryInput models.CategoryDto
+	if err := c.ShouldBindJSON(&categoryInput); err != nil {
+		c.Error(apperrors.InvalidInput(err))
 		return
 	}
 
-	var categoryInput models.Category
-	if err := c.ShouldBindJSON(&categoryInput); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+	category := models.Category{
+		Name:        categoryInput.Name,
+		Description: categoryInput.Description,
 	}
-
-	if err := ch.repo.Create(&categoryInput); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+	if err := ch.repo.Create(&category); err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -58,50 +51,57 @@ func (ch *categoryHandler) AddCategory(c *gin.Context) {
 }
 
 func (ch *categoryHandler) GetMultipleCategories(c *gin.Context) {
-	categories, err := ch.repo.FindMany()
+	pagination, err := libs.NewPagination(c)
+	if err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	fields, err := libs.NewFieldSet(c, &repositories.CategoryFields)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(apperrors.InvalidInput(err))
 		return
 	}
 
+	categories, err := ch.repo.FindMany(pagination, fields)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	pagination.SetLinkHeader(c)
+
 	c.JSON(http.StatusOK, gin.H{
-		"categories": categories,
+		"categories": fields.Filter(models.NewCategoryDetails(categories)),
+		"pagination": pagination,
 	})
 }
 
 func (ch *categoryHandler) GetCategory(c *gin.Context) {
+	fields, err := libs.NewFieldSet(c, &repositories.CategoryFields)
+	if err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	// the products (or only their count) can be expanded with ?expand=products,products_count
 	slug := c.Param("slug")
-	category, err := ch.repo.FindBySlug(slug)
+	category, err := ch.repo.FindBySlug(slug, fields)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"category":                 category,
-		"category_products_length": len(category.Products),
+		"category": fields.Filter(models.NewCategoryDetail(&category)),
 	})
 }
 
 func (ch *categoryHandler) UpdateCategory(c *gin.Context) {
-	payload := libs.CheckUserRole(c)
-	fmt.Println(payload)
-	if payload == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Unauthorized",
-		})
-		return
-	}
-
-	var categoryInput models.Category
+	var categoryInput models.CategoryDto
 	if err := c.ShouldBindJSON(&categoryInput); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(apperrors.InvalidInput(err))
+		return
 	}
 
 	// in this case getting the category record from db is needed in order to get the category id
@@ -110,18 +110,19 @@ func (ch *categoryHandler) UpdateCategory(c *gin.Context) {
 	// id from there... and THERE MUST BE MANY OTHER WAYS to achieve this though and surely this is
 	// not the best way, but for this 'example' project I think doing it this way is enough...
 	slug := c.Param("slug")
-	dbCategory, err := ch.repo.FindBySlug(slug)
+	dbCategory, err := ch.repo.FindBySlug(slug, nil)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(err)
+		return
 	}
 
-	categoryInput.ID = dbCategory.ID
-	if err := ch.repo.Update(&categoryInput); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+	category := models.Category{
+		ID:          dbCategory.ID,
+		Name:        categoryInput.Name,
+		Description: categoryInput.Description,
+	}
+	if err := ch.repo.Update(&category); err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -131,19 +132,9 @@ func (ch *categoryHandler) UpdateCategory(c *gin.Context) {
 }
 
 func (ch *categoryHandler) DeleteCategory(c *gin.Context) {
-	payload := libs.CheckUserRole(c)
-	if payload == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Unauthorized",
-		})
-		return
-	}
-
 	slug := c.Param("slug")
 	if err := ch.repo.Delete(slug); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(err)
 		return
 	}
 
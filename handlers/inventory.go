@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laluardian/gin-ecommerce-api/apperrors"
+	"github.com/laluardian/gin-ecommerce-api/inventory"
+	"github.com/laluardian/gin-ecommerce-api/libs"
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+type InventoryHandler interface {
+	ReserveStock(c *gin.Context)
+	GetReservation(c *gin.Context)
+	ReleaseReservation(c *gin.Context)
+}
+
+type inventoryHandler struct {
+	service inventory.InventoryService
+}
+
+func NewInventoryHandler(db *gorm.DB) InventoryHandler {
+	return &inventoryHandler{
+		inventory.NewInventoryService(db),
+	}
+}
+
+func (ih *inventoryHandler) ReserveStock(c *gin.Context) {
+	userId, _ := xid.FromString(c.Param("userId"))
+	payload := libs.CheckUserId(c, userId)
+	if payload == nil {
+		c.Error(apperrors.Unauthorized("Unauthorized"))
+		return
+	}
+
+	var reservationInput models.ReservationDto
+	if err := c.ShouldBindJSON(&reservationInput); err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	var items []models.ReservationItem
+	for _, item := range reservationInput.Items {
+		items = append(items, models.ReservationItem{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+		})
+	}
+
+	reservation, err := ih.service.Reserve(userId, items)
+	if err != nil {
+		respondInventoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":     "Stock successfully reserved",
+		"reservation": models.NewReservationDetail(&reservation),
+	})
+}
+
+func (ih *inventoryHandler) GetReservation(c *gin.Context) {
+	userId, _ := xid.FromString(c.Param("userId"))
+	payload := libs.CheckUserId(c, userId)
+	if payload == nil {
+		c.Error(apperrors.Unauthorized("Unauthorized"))
+		return
+	}
+
+	reservationId, _ := xid.FromString(c.Param("reservationId"))
+	reservation, err := ih.service.Find(userId, reservationId)
+	if err != nil {
+		respondInventoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reservation": models.NewReservationDetail(&reservation),
+	})
+}
+
+func (ih *inventoryHandler) ReleaseReservation(c *gin.Context) {
+	userId, _ := xid.FromString(c.Param("userId"))
+	payload := libs.CheckUserId(c, userId)
+	if payload == nil {
+		c.Error(apperrors.Unauthorized("Unauthorized"))
+		return
+	}
+
+	reservationId, _ := xid.FromString(c.Param("reservationId"))
+	if err := ih.service.Release(userId, reservationId); err != nil {
+		respondInventoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Reservation successfully released",
+	})
+}
+
+// maps the errors returned by the inventory service to their http responses,
+// this is shared with the order handler since placing an order reserves stock too
+func respondInventoryError(c *gin.Context, err error) {
+	var stockErr *inventory.InsufficientStockError
+
+	switch {
+	case errors.As(err, &stockErr):
+		c.Error(apperrors.Conflict("Insufficient stock").WithExtension("details", stockErr.Lines))
+	case errors.Is(err, inventory.ErrReservationNotFound):
+		c.Error(apperrors.NotFound("Reservation not found"))
+	case errors.Is(err, inventory.ErrReservationExpired), errors.Is(err, inventory.ErrReservationClosed):
+		c.Error(apperrors.Conflict(err.Error()))
+	default:
+		c.Error(err)
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laluardian/gin-ecommerce-api/middlewares"
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/laluardian/gin-ecommerce-api/repositories"
+	"github.com/laluardian/gin-ecommerce-api/testutil"
+	"github.com/rs/xid"
+)
+
+// the whole point of locking the product rows: out of many concurrent
+// reservations of the last units only as many as there are units succeed
+func TestReserveStockConcurrently(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.OpenDB(t)
+
+	const stock, attempts = 3, 20
+	user := testutil.CreateUser(t, db, "alice", "password")
+	product := testutil.CreateProduct(t, db, "Last units", 1000, stock)
+
+	r := gin.New()
+	r.Use(middlewares.ErrorHandler(), testutil.Authenticate(&user))
+	r.POST("/users/:userId/reservations", NewInventoryHandler(db).ReserveStock)
+
+	path := "/users/" + user.ID.String() + "/reservations"
+	body := gin.H{"items": []gin.H{{"product_id": product.ID, "quantity": 1}}}
+
+	statuses := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses <- testutil.DoJSON(r, http.MethodPost, path, body).Code
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := make(map[int]int)
+	for status := range statuses {
+		counts[status]++
+	}
+	if counts[http.StatusCreated] != stock || counts[http.StatusConflict] != attempts-stock {
+		t.Fatalf("statuses = %v, want %d created and %d conflicts", counts, stock, attempts-stock)
+	}
+
+	products, err := repositories.NewProductRepository(db).FindByIds([]xid.ID{product.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if products[0].Quantity != 0 {
+		t.Fatalf("stock left = %d, want 0", products[0].Quantity)
+	}
+
+	var reserved int64
+	err = db.Model(&models.ReservationItem{}).Where("product_id = ?", product.ID).Select("COALESCE(SUM(quantity), 0)").Scan(&reserved).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reserved != stock {
+		t.Fatalf("units reserved = %d, want %d", reserved, stock)
+	}
+}
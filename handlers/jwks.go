@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laluardian/gin-ecommerce-api/libs"
+)
+
+type JwksHandler interface {
+	GetJwks(c *gin.Context)
+}
+
+type jwksHandler struct{}
+
+func NewJwksHandler() JwksHandler {
+	return &jwksHandler{}
+}
+
+// publishes the public keys so other services can verify the tokens issued by
+// this api, the keys don't change often but they do change during a rotation so
+// they are only cached for a little while
+func (jh *jwksHandler) GetJwks(c *gin.Context) {
+	jwks, err := libs.Jwks()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{
+		"keys": jwks,
+	})
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laluardian/gin-ecommerce-api/apperrors"
+	"github.com/laluardian/gin-ecommerce-api/libs"
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/laluardian/gin-ecommerce-api/ratelimit"
+	"github.com/laluardian/gin-ecommerce-api/repositories"
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+const recoveryCodeCount = 10
+
+type MfaHandler interface {
+	EnrollTotp(c *gin.Context)
+	ConfirmTotp(c *gin.Context)
+	DisableTotp(c *gin.Context)
+	RegenerateRecoveryCodes(c *gin.Context)
+}
+
+type mfaHandler struct {
+	repo             repositories.UserRepository
+	recoveryCodeRepo repositories.RecoveryCodeRepository
+	verifier         *mfaVerifier
+	loginLimiter     ratelimit.LoginLimiter
+}
+
+func NewMfaHandler(db *gorm.DB, loginLimiter ratelimit.LoginLimiter) MfaHandler {
+	return &mfaHandler{
+		repositories.NewUserRepository(db),
+		repositories.NewRecoveryCodeRepository(db),
+		newMfaVerifier(db),
+		loginLimiter,
+	}
+}
+
+// checks the second factor, it is shared by the handlers that need to check it
+// (both signing in and managing the second factor itself)
+type mfaVerifier struct {
+	userRepo         repositories.UserRepository
+	recoveryCodeRepo repositories.RecoveryCodeRepository
+}
+
+func newMfaVerifier(db *gorm.DB) *mfaVerifier {
+	return &mfaVerifier{
+		repositories.NewUserRepository(db),
+		repositories.NewRecoveryCodeRepository(db),
+	}
+}
+
+// checks either the totp code or the recovery code, both of them can only be
+// used once
+func (mv *mfaVerifier) verify(user *models.User, input *models.MfaCodeDto) (bool, error) {
+	if input.Code != "" {
+		step, ok := libs.ValidateTotp(user.TotpSecret, input.Code, user.TotpLastStep, time.Now())
+		if !ok {
+			return false, nil
+		}
+
+		used, err := mv.userRepo.UseTotpStep(user.ID, step)
+		if used {
+			user.TotpLastStep = step
+		}
+		return used, err
+	}
+
+	codes, err := mv.recoveryCodeRepo.FindUnused(user.ID)
+	if err != nil {
+		return false, err
+	}
+
+	recoveryCode := strings.ToLower(strings.TrimSpace(input.RecoveryCode))
+	for i := range codes {
+		if libs.ComparePassword(codes[i].CodeHash, recoveryCode) {
+			return mv.recoveryCodeRepo.Use(&codes[i])
+		}
+	}
+
+	return false, nil
+}
+
+// the users can only manage their own second factor, and not while being impersonated
+func (mh *mfaHandler) findUser(c *gin.Context) (models.User, bool) {
+	userId, _ := xid.FromString(c.Param("userId"))
+	payload := libs.CheckUserId(c, userId)
+	if payload == nil {
+		c.Error(apperrors.Unauthorized("Unauthorized"))
+		return models.User{}, false
+	}
+
+	if payload.IsImpersonated() {
+		c.Error(apperrors.Forbidden("Not allowed while impersonating"))
+		return models.User{}, false
+	}
+
+	user, err := mh.repo.FindById(userId)
+	if err != nil {
+		c.Error(err)
+		return user, false
+	}
+
+	return user, true
+}
+
+// generates a new recovery codes for the user, only their hashes are stored so
+// this is the only time they can be seen
+func (mh *mfaHandler) generateRecoveryCodes(user *models.User) ([]string, error) {
+	codes, err := libs.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	var recoveryCodes []models.RecoveryCode
+	for _, code := range codes {
+		codeHash := code
+		if err := libs.HashPassword(&codeHash); err != nil {
+			return nil, err
+		}
+
+		recoveryCodes = append(recoveryCodes, models.RecoveryCode{
+			CodeHash: codeHash,
+			UserID:   user.ID,
+		})
+	}
+
+	if err := mh.recoveryCodeRepo.Replace(user.ID, recoveryCodes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// starts the enrollment, the secret is returned along with the otpauth uri for
+// the authenticator app but two-factor authentication is only enabled once a
+// code is confirmed... the confirmation doesn't ask for the password again since
+// only whoever started the enrollment has the secret to generate the code with
+func (mh *mfaHandler) EnrollTotp(c *gin.Context) {
+	user, ok := mh.findUser(c)
+	if !ok {
+		return
+	}
+
+	var enrollInput models.EnrollTotpDto
+	if err := c.ShouldBindJSON(&enrollInput); err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	if user.TotpEnabledAt != nil {
+		c.Error(apperrors.Conflict("Two-factor authentication is already enabled"))
+		return
+	}
+
+	if !checkCurrentPassword(c, mh.loginLimiter, &user, enrollInput.Password) {
+		return
+	}
+
+	secret, err := libs.GenerateTotpSecret()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	user.TotpSecret = secret
+	user.TotpLastStep = 0
+	if err := mh.repo.UpdateTotp(&user); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": libs.TotpUri(user.Email, secret),
+	})
+}
+
+func (mh *mfaHandler) ConfirmTotp(c *gin.Context) {
+	user, ok := mh.findUser(c)
+	if !ok {
+		return
+	}
+
+	var codeInput models.TotpCodeDto
+	if err := c.ShouldBindJSON(&codeInput); err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	if user.TotpSecret == "" || user.TotpEnabledAt != nil {
+		c.Error(apperrors.Conflict("There is no two-factor authentication enrollment to confirm"))
+		return
+	}
+
+	valid, err := mh.verifier.verify(&user, &models.MfaCodeDto{Code: codeInput.Code})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !valid {
+		c.Error(apperrors.Validation("Invalid code"))
+		return
+	}
+
+	now := time.Now()
+	user.TotpEnabledAt = &now
+	if err := mh.repo.UpdateTotp(&user); err != nil {
+		c.Error(err)
+		return
+	}
+
+	codes, err := mh.generateRecoveryCodes(&user)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication successfully enabled, please sign in again",
+		"recovery_codes": codes,
+	})
+}
+
+// the admins (everyone that has a role) can't disable it since it is mandatory for them
+func (mh *mfaHandler) DisableTotp(c *gin.Context) {
+	user, ok := mh.findUser(c)
+	if !ok {
+		return
+	}
+
+	var codeInput models.MfaCodeDto
+	if err := c.ShouldBindJSON(&codeInput); err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	if user.TotpEnabledAt == nil {
+		c.Error(apperrors.Conflict("Two-factor authentication is not enabled"))
+		return
+	}
+
+	if user.RequiresMfa() {
+		c.Error(apperrors.Forbidden("Two-factor authentication is mandatory for this account"))
+		return
+	}
+
+	valid, err := mh.verifier.verify(&user, &codeInput)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !valid {
+		c.Error(apperrors.Validation("Invalid code"))
+		return
+	}
+
+	user.TotpSecret = ""
+	user.TotpEnabledAt = nil
+	user.TotpLastStep = 0
+	if err := mh.repo.UpdateTotp(&user); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := mh.recoveryCodeRepo.DeleteByUser(user.ID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Two-factor authentication successfully disabled",
+	})
+}
+
+// replaces all of the recovery codes (e.g. when they are running out)
+func (mh *mfaHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	user, ok := mh.findUser(c)
+	if !ok {
+		return
+	}
+
+	var codeInput models.MfaCodeDto
+	if err := c.ShouldBindJSON(&codeInput); err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	if user.TotpEnabledAt == nil {
+		c.Error(apperrors.Conflict("Two-factor authentication is not enabled"))
+		return
+	}
+
+	valid, err := mh.verifier.verify(&user, &codeInput)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !valid {
+		c.Error(apperrors.Validation("Invalid code"))
+		return
+	}
+
+	codes, err := mh.generateRecoveryCodes(&user)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recovery_codes": codes,
+	})
+}
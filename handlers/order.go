@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laluardian/gin-ecommerce-api/apperrors"
+	"github.com/laluardian/gin-ecommerce-api/inventory"
+	"github.com/laluardian/gin-ecommerce-api/libs"
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/laluardian/gin-ecommerce-api/repositories"
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+type OrderHandler interface {
+	AddOrder(c *gin.Context)
+	GetUserOrders(c *gin.Context)
+	GetUserOrder(c *gin.Context)
+	GetMultipleOrders(c *gin.Context)
+	GetOrder(c *gin.Context)
+}
+
+type orderHandler struct {
+	repo        repositories.OrderRepository
+	productRepo repositories.ProductRepository
+	addressRepo repositories.AddressRepository
+	inventory   inventory.InventoryService
+}
+
+func NewOrderHandler(db *gorm.DB) OrderHandler {
+	return &orderHandler{
+		repositories.NewOrderRepository(db),
+		repositories.NewProductRepository(db),
+		repositories.NewAddressRepository(db),
+		inventory.NewInventoryService(db),
+	}
+}
+
+func (oh *orderHandler) AddOrder(c *gin.Context) {
+	userId, _ := xid.FromString(c.Param("userId"))
+	payload := libs.CheckUserId(c, userId)
+	if payload == nil {
+		c.Error(apperrors.Unauthorized("Unauthorized"))
+		return
+	}
+
+	var orderInput models.OrderDto
+	if err := c.ShouldBindJSON(&orderInput); err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	// the shipping address must be one of the user's own addresses
+	address, err := oh.addressRepo.FindByIds(userId, orderInput.AddressID)
+	if err != nil {
+		c.Error(apperrors.Validation("Address not found"))
+		return
+	}
+
+	// the stock of the ordered products is reserved first (unless the client has
+	// already reserved it) so that the order can never take more than what is left
+	var reservation models.Reservation
+	if orderInput.ReservationID.IsNil() {
+		if len(orderInput.Items) == 0 {
+			c.Error(apperrors.Validation("An order must contain at least one item"))
+			return
+		}
+
+		var items []models.ReservationItem
+		for _, item := range orderInput.Items {
+			items = append(items, models.ReservationItem{
+				ProductID: item.ProductID,
+				Quantity:  item.Quantity,
+			})
+		}
+
+		reservation, err = oh.inventory.Reserve(userId, items)
+	} else {
+		reservation, err = oh.inventory.Find(userId, orderInput.ReservationID)
+		if err == nil && reservation.Status != models.ReservationStatusPending {
+			err = inventory.ErrReservationClosed
+		}
+	}
+	if err != nil {
+		respondInventoryError(c, err)
+		return
+	}
+
+	// if anything goes wrong before the reservation is committed, the reserved
+	// stock is given back right away instead of waiting for it to expire
+	committed := false
+	defer func() {
+		if !committed {
+			oh.inventory.Release(userId, reservation.ID)
+		}
+	}()
+
+	var productIds []xid.ID
+	for _, item := range reservation.Items {
+		productIds = append(productIds, item.ProductID)
+	}
+
+	products, err := oh.productRepo.FindByIds(productIds)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	productsById := make(map[xid.ID]models.Product)
+	for _, product := range products {
+		productsById[product.ID] = product
+	}
+
+	var order models.Order
+	order.UserID = userId
+	order.AddressID = address.ID
+	order.Status = models.OrderStatusPending
+
+	for _, reservedItem := range reservation.Items {
+		product, ok := productsById[reservedItem.ProductID]
+		if !ok {
+			c.Error(apperrors.Validation(fmt.Sprintf("Product %s not found", reservedItem.ProductID)))
+			return
+		}
+
+		// snapshot the product's price and discount at the time of purchase
+		item := models.OrderItem{
+			Name:      product.Name,
+			Quantity:  reservedItem.Quantity,
+			Price:     product.Price,
+			Discount:  product.Discount,
+			Subtotal:  uint64(product.DiscountedPrice()) * uint64(reservedItem.Quantity),
+			ProductID: product.ID,
+		}
+		order.Items = append(order.Items, item)
+		order.TotalPrice += item.Subtotal
+	}
+
+	// the order is only created along with the commit of the reservation, which
+	// might have expired in the meantime (then there is no order at all)
+	if err := oh.inventory.Commit(userId, reservation.ID, &order); err != nil {
+		respondInventoryError(c, err)
+		return
+	}
+	committed = true
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "A new order successfully placed",
+		"order":   models.NewOrderDetail(&order),
+	})
+}
+
+func (oh *orderHandler) GetUserOrders(c *gin.Context) {
+	userId, _ := xid.FromString(c.Param("userId"))
+	payload := libs.CheckUserId(c, userId)
+	if payload == nil {
+		c.Error(apperrors.Unauthorized("Unauthorized"))
+		return
+	}
+
+	pagination, err := libs.NewPagination(c)
+	if err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	orders, err := oh.repo.FindByUser(userId, pagination)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	pagination.SetLinkHeader(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"orders":     models.NewOrderDetails(orders),
+		"pagination": pagination,
+	})
+}
+
+func (oh *orderHandler) GetUserOrder(c *gin.Context) {
+	userId, _ := xid.FromString(c.Param("userId"))
+	payload := libs.CheckUserId(c, userId)
+	if payload == nil {
+		c.Error(apperrors.Unauthorized("Unauthorized"))
+		return
+	}
+
+	orderId, _ := xid.FromString(c.Param("orderId"))
+	order, err := oh.repo.FindByIds(userId, orderId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"order": models.NewOrderDetail(&order),
+	})
+}
+
+func (oh *orderHandler) GetMultipleOrders(c *gin.Context) {
+	pagination, err := libs.NewPagination(c)
+	if err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	orders, err := oh.repo.FindMany(pagination)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	pagination.SetLinkHeader(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"orders":     models.NewOrderDetails(orders),
+		"pagination": pagination,
+	})
+}
+
+func (oh *orderHandler) GetOrder(c *gin.Context) {
+	orderId, _ := xid.FromString(c.Param("orderId"))
+	order, err := oh.repo.FindById(orderId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"order": models.NewOrderDetail(&order),
+	})
+}
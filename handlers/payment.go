@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laluardian/gin-ecommerce-api/apperrors"
+	"github.com/laluardian/gin-ecommerce-api/libs"
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/laluardian/gin-ecommerce-api/payments"
+	"github.com/laluardian/gin-ecommerce-api/repositories"
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+// how long a single call to the payment provider is allowed to take
+const paymentProviderTimeout = 10 * time.Second
+
+type PaymentHandler interface {
+	PayOrder(c *gin.Context)
+	GetOrderPayments(c *gin.Context)
+	RefundPayment(c *gin.Context)
+}
+
+type paymentHandler struct {
+	repo      repositories.PaymentRepository
+	orderRepo repositories.OrderRepository
+	provider  payments.PaymentProvider
+}
+
+func NewPaymentHandler(db *gorm.DB, provider payments.PaymentProvider) PaymentHandler {
+	return &paymentHandler{
+		repositories.NewPaymentRepository(db),
+		repositories.NewOrderRepository(db),
+		provider,
+	}
+}
+
+// authorizes and then captures the whole amount of an order, the payment record
+// is kept up to date with the result of every step so a failed payment still
+// tells why it failed... an order can only have one payment in progress, so the
+// same order can't be charged twice by sending the request twice
+func (ph *paymentHandler) PayOrder(c *gin.Context) {
+	userId, _ := xid.FromString(c.Param("userId"))
+	payload := libs.CheckUserId(c, userId)
+	if payload == nil {
+		c.Error(apperrors.Unauthorized("Unauthorized"))
+		return
+	}
+
+	orderId, _ := xid.FromString(c.Param("orderId"))
+	payment := models.Payment{
+		Provider: ph.provider.Name(),
+		Status:   models.PaymentStatusPending,
+	}
+	order, err := ph.repo.CreateForOrder(&payment, userId, orderId)
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrOrderNotPending):
+			c.Error(apperrors.Conflict("Only pending orders can be paid"))
+		case errors.Is(err, repositories.ErrPaymentInProgress):
+			c.Error(apperrors.Conflict("The order already has a payment in progress"))
+		default:
+			c.Error(err)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), paymentProviderTimeout)
+	reference, err := ph.provider.Authorize(ctx, payments.AuthorizeRequest{
+		OrderID: order.ID,
+		Amount:  payment.Amount,
+	})
+	cancel()
+	if err != nil {
+		ph.fail(c, &payment, err)
+		return
+	}
+
+	payment.Reference = reference
+	if err := ph.transition(&payment, models.PaymentStatusAuthorized, ""); err != nil {
+		ph.void(&payment)
+		ph.fail(c, &payment, err)
+		return
+	}
+
+	ctx, cancel = context.WithTimeout(c.Request.Context(), paymentProviderTimeout)
+	err = ph.provider.Capture(ctx, payment.Reference, payment.Amount)
+	cancel()
+	if err != nil {
+		// the amount is still held by the authorization, it is released before the
+		// payment is marked as failed so the user can try again
+		ph.void(&payment)
+		ph.fail(c, &payment, err)
+		return
+	}
+
+	// the money has been taken at this point, when the payment and the order can't
+	// be recorded as paid it is given back before the payment is marked as failed...
+	// if even that fails the payment stays authorized for the sweeper to deal with
+	payment.Status = models.PaymentStatusCaptured
+	if err := ph.repo.CaptureForOrder(&payment, models.PaymentStatusAuthorized); err != nil {
+		payment.Status = models.PaymentStatusAuthorized
+		if refundErr := ph.refund(&payment); refundErr != nil {
+			appErr := apperrors.New(http.StatusBadGateway, apperrors.CodePaymentFailed, "The payment could not be processed, please try again later")
+			appErr.Err = err
+			c.Error(appErr)
+			return
+		}
+		ph.fail(c, &payment, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Order successfully paid",
+		"payment": models.NewPaymentDetail(&payment),
+	})
+}
+
+func (ph *paymentHandler) GetOrderPayments(c *gin.Context) {
+	userId, _ := xid.FromString(c.Param("userId"))
+	payload := libs.CheckUserId(c, userId)
+	if payload == nil {
+		c.Error(apperrors.Unauthorized("Unauthorized"))
+		return
+	}
+
+	orderId, _ := xid.FromString(c.Param("orderId"))
+	order, err := ph.orderRepo.FindByIds(userId, orderId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	orderPayments, err := ph.repo.FindByOrder(order.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"payments": models.NewPaymentDetails(orderPayments),
+	})
+}
+
+// the payment is moved to refunding before the provider is asked to refund it,
+// only one request can do that so the same payment is never refunded twice
+func (ph *paymentHandler) RefundPayment(c *gin.Context) {
+	paymentId, _ := xid.FromString(c.Param("paymentId"))
+	payment, err := ph.repo.FindById(paymentId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if payment.Status != models.PaymentStatusCaptured {
+		c.Error(apperrors.Conflict("Only captured payments can be refunded"))
+		return
+	}
+
+	if err := ph.transition(&payment, models.PaymentStatusRefunding, ""); err != nil {
+		if errors.Is(err, repositories.ErrPaymentChanged) {
+			c.Error(apperrors.Conflict("The payment is already being refunded"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), paymentProviderTimeout)
+	err = ph.provider.Refund(ctx, payment.Reference, payment.Amount)
+	cancel()
+	if err != nil {
+		// a failed refund doesn't change the payment, the money is still captured
+		if revertErr := ph.transition(&payment, models.PaymentStatusCaptured, ""); revertErr != nil {
+			log.Println("Error moving payment", payment.ID, "back to captured:", revertErr)
+		}
+		appErr := apperrors.New(http.StatusBadGateway, apperrors.CodePaymentFailed, "The payment could not be refunded, please try again later")
+		appErr.Err = err
+		c.Error(appErr)
+		return
+	}
+
+	if err := ph.transition(&payment, models.PaymentStatusRefunded, ""); err != nil {
+		c.Error(err)
+		return
+	}
+
+	order := models.Order{ID: payment.OrderID}
+	if err := ph.orderRepo.UpdateStatus(&order, models.OrderStatusRefunded); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Payment successfully refunded",
+		"payment": models.NewPaymentDetail(&payment),
+	})
+}
+
+func (ph *paymentHandler) transition(payment *models.Payment, status, reason string) error {
+	if !payment.CanTransitionTo(status) {
+		return errors.New("payment cannot move from " + payment.Status + " to " + status)
+	}
+
+	from := payment.Status
+	payment.Status = status
+	payment.FailureReason = reason
+	if err := ph.repo.Transition(payment, from); err != nil {
+		payment.Status = from
+		return err
+	}
+
+	return nil
+}
+
+// releases the amount held by the authorization of a payment, a failure is only
+// logged since the provider releases it on its own sooner or later anyway
+func (ph *paymentHandler) void(payment *models.Payment) {
+	ctx, cancel := context.WithTimeout(context.Background(), paymentProviderTimeout)
+	defer cancel()
+
+	if err := ph.provider.Void(ctx, payment.Reference); err != nil {
+		log.Println("Error voiding the authorization of payment", payment.ID, ":", err)
+	}
+}
+
+// gives back the captured amount of a payment that couldn't be recorded as paid
+func (ph *paymentHandler) refund(payment *models.Payment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), paymentProviderTimeout)
+	defer cancel()
+
+	err := ph.provider.Refund(ctx, payment.Reference, payment.Amount)
+	if err != nil {
+		log.Println("Error refunding the capture of payment", payment.ID, ":", err)
+	}
+	return err
+}
+
+// marks the payment as failed and responds with the reason it failed, whatever
+// the provider said about it is only logged
+func (ph *paymentHandler) fail(c *gin.Context, payment *models.Payment, err error) {
+	appErr := apperrors.New(http.StatusBadGateway, apperrors.CodePaymentFailed, "The payment could not be processed, please try again later")
+	switch {
+	case errors.Is(err, payments.ErrDeclined):
+		appErr = apperrors.New(http.StatusPaymentRequired, apperrors.CodePaymentDeclined, "The payment was declined")
+	case errors.Is(err, repositories.ErrOrderNotPending):
+		appErr = apperrors.Conflict("Only pending orders can be paid")
+	case errors.Is(err, payments.ErrTimeout):
+		appErr.Status = http.StatusGatewayTimeout
+		appErr.Message = "The payment provider did not respond in time, please try again later"
+	}
+	appErr.Err = err
+
+	if updateErr := ph.transition(payment, models.PaymentStatusFailed, appErr.Message); updateErr != nil {
+		c.Error(updateErr)
+		return
+	}
+
+	c.Error(appErr.WithExtension("payment", models.NewPaymentDetail(payment)))
+}
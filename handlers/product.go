@@ -1,19 +1,32 @@
 package handlers
 
 import (
+	"fmt"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/laluardian/gin-ecommerce-api/apperrors"
 	"github.com/laluardian/gin-ecommerce-api/libs"
 	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/laluardian/gin-ecommerce-api/notifications"
 	"github.com/laluardian/gin-ecommerce-api/repositories"
 	"github.com/rs/xid"
 	"gorm.io/gorm"
 )
 
+const (
+	defaultSuggestionLimit = 10
+	maxSuggestionLimit     = 20
+)
+
 type ProductHandler interface {
 	AddProduct(c *gin.Context)
 	GetMultipleProducts(c *gin.Context)
+	GetProductFacets(c *gin.Context)
+	SuggestProducts(c *gin.Context)
 	GetProduct(c *gin.Context)
 	UpdateProduct(c *gin.Context)
 	DeleteProduct(c *gin.Context)
@@ -21,31 +34,24 @@ type ProductHandler interface {
 }
 
 type productHandler struct {
-	repo repositories.ProductRepository
+	repo     repositories.ProductRepository
+	notifier notifications.Notifier
 }
 
-func NewProductHandler(db *gorm.DB) ProductHandler {
+func NewProductHandler(db *gorm.DB, notifier notifications.Notifier) ProductHandler {
 	return &productHandler{
 		repositories.NewProductRepository(db),
+		notifier,
 	}
 }
 
 func (ph *productHandler) AddProduct(c *gin.Context) {
-	payload := libs.CheckUserRole(c)
-	if payload == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Unauthorized",
-		})
-		return
-	}
-
 	// TODO check if the user exist for extra security (other handlers might need, too)
 
 	var productInput models.ProductDto
 	if err := c.ShouldBindJSON(&productInput); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(apperrors.InvalidInput(err))
+		return
 	}
 
 	var product models.Product
@@ -64,9 +70,7 @@ func (ph *productHandler) AddProduct(c *gin.Context) {
 	}
 
 	if err := ph.repo.Create(&product); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(err)
 		return
 	}
 
@@ -76,56 +80,162 @@ func (ph *productHandler) AddProduct(c *gin.Context) {
 }
 
 func (ph *productHandler) GetMultipleProducts(c *gin.Context) {
-	keyword := c.Query("search")
-	// if the keyword is empty all products will be returned
-	products, err := ph.repo.FindMany(keyword)
+	pagination, err := libs.NewPagination(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(apperrors.InvalidInput(err))
 		return
 	}
 
+	filter, err := parseProductFilter(c)
+	if err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	fields, err := libs.NewFieldSet(c, &repositories.ProductSummaryFields)
+	if err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	if !filter.IsSortedById() {
+		if err := pagination.UseOffset(); err != nil {
+			c.Error(apperrors.InvalidInput(err))
+			return
+		}
+	}
+
+	// if no filter is set all products will be returned
+	products, err := ph.repo.FindMany(&filter, pagination, fields)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	pagination.SetLinkHeader(c)
+
 	c.JSON(http.StatusOK, gin.H{
-		"products": products,
+		"products":   fields.Filter(models.NewProductSummaries(products)),
+		"filters":    filter,
+		"pagination": pagination,
 	})
 }
 
-func (ph *productHandler) GetProduct(c *gin.Context) {
-	productId, _ := xid.FromString(c.Param("productId"))
-	product, err := ph.repo.FindById(productId)
+// the facets are counted for the same filters (and search) as the product list
+func (ph *productHandler) GetProductFacets(c *gin.Context) {
+	filter, err := parseProductFilter(c)
+	if err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	facets, err := ph.repo.FindFacets(&filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"product": product,
+		"facets":  facets,
+		"filters": filter,
 	})
 }
 
-func (ph *productHandler) UpdateProduct(c *gin.Context) {
-	payload := libs.CheckUserRole(c)
-	if payload == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Unauthorized",
-		})
+func (ph *productHandler) SuggestProducts(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.Error(apperrors.Validation("The q param is required"))
+		return
+	}
+
+	limit := defaultSuggestionLimit
+	if param := c.Query("limit"); param != "" {
+		n, err := strconv.Atoi(param)
+		if err != nil || n < 1 || n > maxSuggestionLimit {
+			c.Error(apperrors.Validation(fmt.Sprintf("limit must be a number between 1 and %d", maxSuggestionLimit)))
+			return
+		}
+		limit = n
+	}
+
+	// the suggestions can be narrowed down with the same filters as the product list
+	filter, err := parseProductFilter(c)
+	if err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	suggestions, err := ph.repo.Suggest(query, &filter, limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"suggestions": suggestions,
+	})
+}
+
+// the categories can be sent either as a repeated param (?category=a&category=b)
+// or as a comma separated list (?category=a,b), or both
+func parseProductFilter(c *gin.Context) (models.ProductFilter, error) {
+	var filter models.ProductFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		return filter, err
+	}
+
+	filter.Search = strings.TrimSpace(filter.Search)
+
+	var categories []string
+	for _, category := range filter.Categories {
+		for _, slug := range strings.Split(category, ",") {
+			if slug = strings.TrimSpace(slug); slug != "" {
+				categories = append(categories, slug)
+			}
+		}
+	}
+	filter.Categories = categories
+
+	return filter, filter.Validate()
+}
+
+func (ph *productHandler) GetProduct(c *gin.Context) {
+	fields, err := libs.NewFieldSet(c, &repositories.ProductDetailFields)
+	if err != nil {
+		c.Error(apperrors.InvalidInput(err))
 		return
 	}
 
+	productId, _ := xid.FromString(c.Param("productId"))
+	product, err := ph.repo.FindById(productId, fields)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"product": fields.Filter(models.NewProductDetail(&product)),
+	})
+}
+
+func (ph *productHandler) UpdateProduct(c *gin.Context) {
 	var productInput models.ProductDto
 	if err := c.ShouldBindJSON(&productInput); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(apperrors.InvalidInput(err))
 		return
 	}
 
-	var product models.Product
 	productId, _ := xid.FromString(c.Param("productId"))
 
+	// the product is fetched before it is updated in order to tell the users who
+	// wishlisted it what has changed
+	dbProduct, err := ph.repo.FindWithWishlistedBy(productId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var product models.Product
 	product.ID = productId
 	product.Name = productInput.Name
 	product.Description = productInput.Description
@@ -136,9 +246,7 @@ func (ph *productHandler) UpdateProduct(c *gin.Context) {
 	// clear the Categories field then repopulate it later in case some of
 	// the categories are removed from the product by the admin
 	if err := ph.repo.ClearCategories(&product); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(err)
 		return
 	}
 
@@ -149,34 +257,56 @@ func (ph *productHandler) UpdateProduct(c *gin.Context) {
 	}
 
 	if err := ph.repo.Update(&product); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(err)
 		return
 	}
 
+	go ph.notifyWishlistingUsers(dbProduct, &product)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Product successfully updated",
 	})
 }
 
-func (ph *productHandler) DeleteProduct(c *gin.Context) {
-	payload := libs.CheckUserRole(c)
-	if payload == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Unauthorized",
-		})
+// the after is nil when the product has been deleted
+func (ph *productHandler) notifyWishlistingUsers(before models.Product, after *models.Product) {
+	changes := models.ProductChanges(&before, after)
+	if len(changes) == 0 {
 		return
 	}
 
+	name := before.Name
+	if after != nil {
+		name = after.Name
+	}
+
+	subject := fmt.Sprintf("Update on %s from your wishlist", name)
+	message := fmt.Sprintf("%s: %s", name, strings.Join(changes, ", "))
+	for _, user := range before.WishlistedBy {
+		if err := ph.notifier.Notify(user.ID, subject, message); err != nil {
+			log.Println("Error notifying user", user.ID, "about a wishlist change:", err)
+		}
+	}
+}
+
+func (ph *productHandler) DeleteProduct(c *gin.Context) {
 	productId, _ := xid.FromString(c.Param("productId"))
+
+	// the wishlist items are kept without their product, the users who
+	// wishlisted it are fetched beforehand to tell them it is gone
+	dbProduct, err := ph.repo.FindWithWishlistedBy(productId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
 	if err := ph.repo.Delete(productId); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(err)
 		return
 	}
 
+	go ph.notifyWishlistingUsers(dbProduct, nil)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Product successfully deleted",
 	})
@@ -185,11 +315,10 @@ func (ph *productHandler) DeleteProduct(c *gin.Context) {
 func (ph *productHandler) AddOrRemoveWishlistProduct(c *gin.Context) {
 	var product models.Product
 	productId, _ := xid.FromString(c.Param("productId"))
-	product, err := ph.repo.FindById(productId)
+	product, err := ph.repo.FindWithWishlistedBy(productId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(err)
+		return
 	}
 
 	// note that this method updates wishlist items from a product perspective, that means
@@ -205,9 +334,7 @@ func (ph *productHandler) AddOrRemoveWishlistProduct(c *gin.Context) {
 	for _, dbUser := range product.WishlistedBy {
 		if dbUser.ID == user.ID {
 			if err := ph.repo.RemoveFromWishlist(&product, &user); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": err.Error(),
-				})
+				c.Error(err)
 				return
 			}
 
@@ -219,11 +346,8 @@ func (ph *productHandler) AddOrRemoveWishlistProduct(c *gin.Context) {
 	}
 
 	// otherwise, add the product to wishlist
-	product.WishlistedBy = append(product.WishlistedBy, &user)
-	if err := ph.repo.AddToWishlist(&product); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+	if err := ph.repo.AddToWishlist(&product, &user); err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laluardian/gin-ecommerce-api/apperrors"
+	"github.com/laluardian/gin-ecommerce-api/libs"
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/laluardian/gin-ecommerce-api/repositories"
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+type ReviewHandler interface {
+	AddReview(c *gin.Context)
+	GetProductReviews(c *gin.Context)
+	UpdateReview(c *gin.Context)
+	DeleteReview(c *gin.Context)
+	HideReview(c *gin.Context)
+	UnhideReview(c *gin.Context)
+}
+
+type reviewHandler struct {
+	repo        repositories.ReviewRepository
+	productRepo repositories.ProductRepository
+}
+
+func NewReviewHandler(db *gorm.DB) ReviewHandler {
+	return &reviewHandler{
+		repositories.NewReviewRepository(db),
+		repositories.NewProductRepository(db),
+	}
+}
+
+func (rh *reviewHandler) AddReview(c *gin.Context) {
+	payload := c.MustGet(libs.JwtPayloadKey).(*libs.JwtPayload)
+
+	var reviewInput models.ReviewDto
+	if err := c.ShouldBindJSON(&reviewInput); err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	productId, _ := xid.FromString(c.Param("productId"))
+	products, err := rh.productRepo.FindByIds([]xid.ID{productId})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if len(products) == 0 {
+		c.Error(apperrors.NotFound("Product not found"))
+		return
+	}
+
+	// a user can only review a product once, the existing review should be edited instead
+	_, err = rh.repo.FindByUser(productId, payload.Sub)
+	if err == nil {
+		c.Error(apperrors.Conflict("You have already reviewed this product"))
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Error(err)
+		return
+	}
+
+	review := models.Review{
+		Rating:    reviewInput.Rating,
+		Title:     reviewInput.Title,
+		Body:      reviewInput.Body,
+		UserID:    payload.Sub,
+		ProductID: productId,
+	}
+	if err := rh.repo.Create(&review); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "A new review successfully added",
+		"review":  models.NewReviewDetail(&review),
+	})
+}
+
+func (rh *reviewHandler) GetProductReviews(c *gin.Context) {
+	productId, _ := xid.FromString(c.Param("productId"))
+	pagination, err := libs.NewPagination(c)
+	if err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	reviews, err := rh.repo.FindByProduct(productId, pagination)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	pagination.SetLinkHeader(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"reviews":    models.NewReviewDetails(reviews),
+		"pagination": pagination,
+	})
+}
+
+func (rh *reviewHandler) UpdateReview(c *gin.Context) {
+	payload := c.MustGet(libs.JwtPayloadKey).(*libs.JwtPayload)
+
+	var reviewInput models.ReviewDto
+	if err := c.ShouldBindJSON(&reviewInput); err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	productId, _ := xid.FromString(c.Param("productId"))
+	reviewId, _ := xid.FromString(c.Param("reviewId"))
+	review, err := rh.repo.FindByIds(productId, reviewId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// only the author can edit a review
+	if review.UserID != payload.Sub {
+		c.Error(apperrors.Forbidden("Only the author can edit the review"))
+		return
+	}
+
+	review.Rating = reviewInput.Rating
+	review.Title = reviewInput.Title
+	review.Body = reviewInput.Body
+	if err := rh.repo.Update(&review); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Review successfully updated",
+	})
+}
+
+func (rh *reviewHandler) DeleteReview(c *gin.Context) {
+	payload := c.MustGet(libs.JwtPayloadKey).(*libs.JwtPayload)
+
+	productId, _ := xid.FromString(c.Param("productId"))
+	reviewId, _ := xid.FromString(c.Param("reviewId"))
+	review, err := rh.repo.FindByIds(productId, reviewId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// a review can be deleted by its author or by a moderator
+	if review.UserID != payload.Sub && libs.CheckPermission(c, models.PermissionReviewsModerate) == nil {
+		c.Error(apperrors.Forbidden("Only the author or a moderator can delete the review"))
+		return
+	}
+
+	if err := rh.repo.Delete(review.ID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Review successfully deleted",
+	})
+}
+
+func (rh *reviewHandler) HideReview(c *gin.Context) {
+	rh.setHidden(c, true, "Review successfully hidden")
+}
+
+func (rh *reviewHandler) UnhideReview(c *gin.Context) {
+	rh.setHidden(c, false, "Review successfully unhidden")
+}
+
+// hiding and unhiding reviews is how the moderators moderate them
+func (rh *reviewHandler) setHidden(c *gin.Context, hidden bool, message string) {
+	productId, _ := xid.FromString(c.Param("productId"))
+	reviewId, _ := xid.FromString(c.Param("reviewId"))
+	review, err := rh.repo.FindByIds(productId, reviewId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := rh.repo.SetHidden(&review, hidden); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+	})
+}
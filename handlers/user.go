@@ -1,12 +1,22 @@
 package handlers
 
 import (
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/laluardian/gin-ecommerce-api/apperrors"
 	"github.com/laluardian/gin-ecommerce-api/libs"
+	"github.com/laluardian/gin-ecommerce-api/mailer"
 	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/laluardian/gin-ecommerce-api/ratelimit"
 	"github.com/laluardian/gin-ecommerce-api/repositories"
+	"github.com/laluardian/gin-ecommerce-api/revocation"
 	"github.com/rs/xid"
 	"gorm.io/gorm"
 )
@@ -14,82 +24,603 @@ import (
 type UserHandler interface {
 	SignUp(c *gin.Context)
 	SignIn(c *gin.Context)
+	SignInMfa(c *gin.Context)
+	RefreshToken(c *gin.Context)
+	SignOut(c *gin.Context)
 	GetUser(c *gin.Context)
 	GetMultipleUsers(c *gin.Context)
 	GetUserWishlist(c *gin.Context)
 	UpdateUser(c *gin.Context)
 	UpdatePassword(c *gin.Context)
 	DeleteUser(c *gin.Context)
+	SignOutEverywhere(c *gin.Context)
+	VerifyEmail(c *gin.Context)
+	ForgotPassword(c *gin.Context)
+	ResetPassword(c *gin.Context)
+	ResendVerificationEmail(c *gin.Context)
 }
 
 type userHandler struct {
-	repo repositories.UserRepository
+	repo          repositories.UserRepository
+	sessionRepo   repositories.SessionRepository
+	userTokenRepo repositories.UserTokenRepository
+	revocations   revocation.RevocationStore
+	mails         *userMailer
+	loginLimiter  ratelimit.LoginLimiter
+	mfa           *mfaVerifier
 }
 
-func NewUserHandler(db *gorm.DB) UserHandler {
+func NewUserHandler(db *gorm.DB, revocations revocation.RevocationStore, mailer mailer.Mailer, loginLimiter ratelimit.LoginLimiter) UserHandler {
 	return &userHandler{
 		repositories.NewUserRepository(db),
+		repositories.NewSessionRepository(db),
+		repositories.NewUserTokenRepository(db),
+		revocations,
+		newUserMailer(db, mailer),
+		loginLimiter,
+		newMfaVerifier(db),
 	}
 }
 
+// tells the client to back off, the wait is rounded up to whole seconds
+func respondTooManyAttempts(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	c.Error(apperrors.TooManyRequests("Too many failed sign in attempts, please try again later"))
+}
+
+// checks the current password before a sensitive change (e.g. the email or the
+// second factor), the wrong passwords count towards the same lockout as the ones
+// on signing in, otherwise this would be a way around it for anyone holding an
+// access token
+func checkCurrentPassword(c *gin.Context, loginLimiter ratelimit.LoginLimiter, user *models.User, password string) bool {
+	wait, err := loginLimiter.Check(user.Email, c.ClientIP())
+	if err != nil {
+		c.Error(err)
+		return false
+	}
+	if wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return false
+	}
+
+	wait, err = loginLimiter.Attempt(user.Email, c.ClientIP())
+	if err != nil {
+		c.Error(err)
+		return false
+	}
+	if wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return false
+	}
+
+	if libs.ComparePassword(user.Password, password) {
+		if err := loginLimiter.Undo(user.Email, c.ClientIP()); err != nil {
+			log.Println("Error undoing the sign in attempt:", err)
+		}
+		return true
+	}
+
+	wait, err = loginLimiter.Check(user.Email, c.ClientIP())
+	if err != nil {
+		log.Println("Error checking the failed sign in attempts:", err)
+	}
+	if wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return false
+	}
+
+	c.Error(apperrors.Validation("Invalid password"))
+	return false
+}
+
+// issues a short-lived access token along with a refresh token, the refresh
+// token's session joins the given family (a nil family id starts a new one) and
+// remembers whether the user has signed in with a second factor
+func (uh *userHandler) issueTokens(c *gin.Context, user *models.User, familyId xid.ID, mfa bool) (gin.H, error) {
+	accessToken, err := libs.GenerateToken(user, mfa)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, tokenHash, err := libs.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := models.Session{
+		FamilyID:  familyId,
+		TokenHash: tokenHash,
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+		Mfa:       mfa,
+		ExpiresAt: time.Now().Add(libs.RefreshTokenTtl()),
+		UserID:    user.ID,
+	}
+	if err := uh.sessionRepo.Create(&session); err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(libs.AccessTokenTtl().Seconds()),
+	}, nil
+}
+
 func (uh *userHandler) SignUp(c *gin.Context) {
-	var userInput models.User
-	if err := c.ShouldBindJSON(&userInput); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+	var signUpInput models.SignUpDto
+	if err := c.ShouldBindJSON(&signUpInput); err != nil {
+		c.Error(apperrors.InvalidInput(err))
 		return
 	}
 
+	userInput := models.User{
+		Username: signUpInput.Username,
+		Email:    signUpInput.Email,
+		Password: signUpInput.Password,
+	}
 	if err := libs.HashPassword(&userInput.Password); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(err)
 		return
 	}
 
+	// a username or an email that is already taken is a conflict on that field
 	if err := uh.repo.Create(&userInput); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(err)
+		return
+	}
+
+	// the user can already sign in and browse but can't do much else until the
+	// email address is verified, failing to send the email is not fatal since
+	// it can be sent again
+	if err := uh.mails.sendVerificationEmail(&userInput); err != nil {
+		log.Println("Error sending verification email:", err)
+	}
+
+	tokens, err := uh.issueTokens(c, &userInput, xid.NilID(), false)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, tokens)
+}
+
+// verifies the email address of the user the token has been sent to, the
+// tokens that are issued afterwards (e.g. by refreshing them) tell that the
+// email is verified
+func (uh *userHandler) VerifyEmail(c *gin.Context) {
+	var tokenInput models.UserTokenDto
+	if err := c.ShouldBindJSON(&tokenInput); err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	userToken, err := uh.findUserToken(models.UserTokenPurposeEmailVerification, tokenInput.Token)
+	if err != nil {
+		if errors.Is(err, libs.ErrInvalidUserToken) {
+			c.Error(apperrors.InvalidInput(err))
+			return
+		}
+
+		c.Error(err)
 		return
 	}
 
-	token, _ := libs.GenerateToken(&userInput)
+	if err := uh.repo.MarkEmailVerified(userToken.UserID); err != nil {
+		c.Error(err)
+		return
+	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"access_token": token,
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email successfully verified",
 	})
 }
 
+// checks the signature of the token, finds it and uses it up so it can't be used
+// again, any token that is not usable (anymore) is reported as ErrInvalidUserToken
+func (uh *userHandler) findUserToken(purpose, token string) (models.UserToken, error) {
+	tokenHash, err := libs.VerifyUserToken(purpose, token)
+	if err != nil {
+		return models.UserToken{}, err
+	}
+
+	userToken, err := uh.userTokenRepo.FindByTokenHash(purpose, tokenHash)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return userToken, libs.ErrInvalidUserToken
+	}
+	if err != nil {
+		return userToken, err
+	}
+
+	used, err := uh.userTokenRepo.Use(&userToken)
+	if err != nil {
+		return userToken, err
+	}
+	if !used {
+		return userToken, libs.ErrInvalidUserToken
+	}
+
+	return userToken, nil
+}
+
+// the response is always the same whether the email address belongs to a user or
+// not so this endpoint can't be used to find out who is registered... the email
+// is sent in the background for the same reason, otherwise the response would
+// take longer for the registered email addresses
+func (uh *userHandler) ForgotPassword(c *gin.Context) {
+	var forgotInput models.ForgotPasswordDto
+	if err := c.ShouldBindJSON(&forgotInput); err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	go func() {
+		user, err := uh.repo.FindByEmail(forgotInput.Email)
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Println("Error finding user for password reset:", err)
+			}
+			return
+		}
+
+		if err := uh.mails.sendPasswordResetEmail(&user); err != nil {
+			log.Println("Error sending password reset email:", err)
+		}
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "If the email address is registered, a password reset token has been sent to it",
+	})
+}
+
+// sets a new password using a token sent by ForgotPassword, all of the user's
+// sessions and tokens are revoked afterwards since whoever had the old password
+// might still be signed in
+func (uh *userHandler) ResetPassword(c *gin.Context) {
+	var resetInput models.ResetPasswordDto
+	if err := c.ShouldBindJSON(&resetInput); err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	userToken, err := uh.findUserToken(models.UserTokenPurposePasswordReset, resetInput.Token)
+	if err != nil {
+		if errors.Is(err, libs.ErrInvalidUserToken) {
+			c.Error(apperrors.InvalidInput(err))
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+
+	if err := libs.HashPassword(&resetInput.Password); err != nil {
+		c.Error(err)
+		return
+	}
+
+	var user models.User
+	user.ID = userToken.UserID
+	user.Password = resetInput.Password
+	if err := uh.repo.UpdatePassword(&user); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := uh.revocations.RevokeUser(user.ID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Password successfully reset, please sign in again",
+	})
+}
+
+func (uh *userHandler) ResendVerificationEmail(c *gin.Context) {
+	userId, _ := xid.FromString(c.Param("userId"))
+	payload := libs.CheckUserId(c, userId)
+	if payload == nil {
+		c.Error(apperrors.Unauthorized("Unauthorized"))
+		return
+	}
+
+	user, err := uh.repo.FindById(userId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if user.EmailVerifiedAt != nil {
+		c.Error(apperrors.Conflict("Email is already verified"))
+		return
+	}
+
+	if err := uh.mails.sendVerificationEmail(&user); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Verification email successfully sent",
+	})
+}
+
+// the failed attempts are counted per account and per ip address, once there are
+// too many of them the client is locked out for a while (see ratelimit.Policy)...
+// the attempts on the emails that don't belong to anyone are counted too so the
+// responses don't tell which ones are registered
 func (uh *userHandler) SignIn(c *gin.Context) {
-	var userInput models.User
+	var userInput models.SignInDto
 	if err := c.ShouldBindJSON(&userInput); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(apperrors.InvalidInput(err))
+		return
 	}
 
 	const signInErrMsg = "Invalid email or password"
 
+	wait, err := uh.loginLimiter.Check(userInput.Email, c.ClientIP())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return
+	}
+
+	// the attempt is counted before the password is compared and given back when
+	// the password turns out to be right
+	wait, err = uh.loginLimiter.Attempt(userInput.Email, c.ClientIP())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return
+	}
+
 	user, err := uh.repo.FindByEmail(userInput.Email)
+	if err == nil && libs.ComparePassword(user.Password, userInput.Password) {
+		// the failed attempts are only forgotten once the second factor is checked
+		// too, otherwise knowing the password would be enough to guess the codes
+		if user.SuspendedAt != nil || user.PasswordResetRequired || user.TotpEnabledAt != nil {
+			if err := uh.loginLimiter.Undo(userInput.Email, c.ClientIP()); err != nil {
+				log.Println("Error undoing the sign in attempt:", err)
+			}
+		}
+
+		if user.SuspendedAt != nil {
+			c.Error(apperrors.Forbidden("Account is suspended"))
+			return
+		}
+
+		if user.PasswordResetRequired {
+			c.Error(apperrors.Forbidden("A password reset is required, a reset token has been sent to your email"))
+			return
+		}
+
+		if user.TotpEnabledAt != nil {
+			mfaToken, err := libs.GenerateMfaPendingToken(&user)
+			if err != nil {
+				c.Error(err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"mfa_required": true,
+				"mfa_token":    mfaToken,
+				"expires_in":   int(libs.MfaPendingTtl.Seconds()),
+			})
+			return
+		}
+
+		if err := uh.loginLimiter.Succeed(userInput.Email, c.ClientIP()); err != nil {
+			log.Println("Error resetting the failed sign in attempts:", err)
+		}
+
+		tokens, err := uh.issueTokens(c, &user, xid.NilID(), false)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, tokens)
+		return
+	}
+
+	uh.respondFailedAttempt(c, userInput.Email, signInErrMsg)
+}
+
+// the second step of signing in when two-factor authentication is enabled, the
+// mfa pending token from the first step is exchanged for the real tokens with a
+// totp code (or a recovery code)... the failed attempts count towards the same
+// lockout as the wrong passwords
+func (uh *userHandler) SignInMfa(c *gin.Context) {
+	var mfaInput models.MfaSignInDto
+	if err := c.ShouldBindJSON(&mfaInput); err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	const mfaTokenErrMsg = "Invalid or expired mfa token, please sign in again"
+
+	payload, err := libs.VerifyToken(mfaInput.MfaToken)
+	if err != nil || payload.Scope != libs.ScopeMfaPending {
+		c.Error(apperrors.Unauthorized(mfaTokenErrMsg))
+		return
+	}
+
+	revoked, err := uh.revocations.IsRevoked(payload)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if revoked {
+		c.Error(apperrors.Unauthorized(mfaTokenErrMsg))
+		return
+	}
+
+	user, err := uh.repo.FindById(payload.Sub)
+	if err != nil || user.SuspendedAt != nil || user.TotpEnabledAt == nil {
+		c.Error(apperrors.Unauthorized(mfaTokenErrMsg))
+		return
+	}
+
+	wait, err := uh.loginLimiter.Check(user.Email, c.ClientIP())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return
+	}
+
+	wait, err = uh.loginLimiter.Attempt(user.Email, c.ClientIP())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return
+	}
+
+	valid, err := uh.mfa.verify(&user, &mfaInput.MfaCodeDto)
+	if err != nil {
+		if err := uh.loginLimiter.Undo(user.Email, c.ClientIP()); err != nil {
+			log.Println("Error undoing the sign in attempt:", err)
+		}
+		c.Error(err)
+		return
+	}
+
+	if !valid {
+		uh.respondFailedAttempt(c, user.Email, "Invalid code")
+		return
+	}
+
+	// the mfa pending token can only be exchanged once
+	if err := uh.revocations.RevokeToken(payload); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := uh.loginLimiter.Succeed(user.Email, c.ClientIP()); err != nil {
+		log.Println("Error resetting the failed sign in attempts:", err)
+	}
+
+	tokens, err := uh.issueTokens(c, &user, xid.NilID(), true)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// the failed attempt has already been counted, the client is told to wait when
+// it was the one that locked the sign in out
+func (uh *userHandler) respondFailedAttempt(c *gin.Context, email, msg string) {
+	wait, err := uh.loginLimiter.Check(email, c.ClientIP())
+	if err != nil {
+		log.Println("Error checking the failed sign in attempts:", err)
+	}
+	if wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return
+	}
+
+	c.Error(apperrors.Unauthorized(msg))
+}
+
+// exchanges a refresh token for a new pair of tokens, the used refresh token is
+// rotated so it can't be used again... if it is used again anyway it means that
+// someone else has a copy of it, so every session of its family is revoked
+func (uh *userHandler) RefreshToken(c *gin.Context) {
+	var tokenInput models.RefreshTokenDto
+	if err := c.ShouldBindJSON(&tokenInput); err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	const refreshErrMsg = "Invalid refresh token"
+
+	session, err := uh.sessionRepo.FindByTokenHash(libs.HashRefreshToken(tokenInput.RefreshToken))
+	if err != nil || session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		c.Error(apperrors.Unauthorized(refreshErrMsg))
+		return
+	}
+
+	rotated, err := uh.sessionRepo.Rotate(&session)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": signInErrMsg,
-		})
+		c.Error(err)
 		return
 	}
 
-	if isTrue := libs.ComparePassword(user.Password, userInput.Password); isTrue {
-		token, _ := libs.GenerateToken(&user)
-		c.JSON(http.StatusOK, gin.H{
-			"access_token": token,
-		})
+	if !rotated {
+		// the family must not outlive the reuse, so failing to revoke it is an
+		// error rather than just an unauthorized request
+		if err := uh.sessionRepo.RevokeFamily(session.FamilyID); err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.Error(apperrors.Unauthorized("Refresh token reuse detected, please sign in again"))
 		return
 	}
 
-	c.JSON(http.StatusInternalServerError, gin.H{
-		"error": signInErrMsg,
+	user, err := uh.repo.FindById(session.UserID)
+	if err != nil || user.SuspendedAt != nil {
+		c.Error(apperrors.Unauthorized(refreshErrMsg))
+		return
+	}
+
+	tokens, err := uh.issueTokens(c, &user, session.FamilyID, session.Mfa)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// revokes the session of the refresh token (along with the rest of its family),
+// signing out with an unknown token is not an error since there is nothing to do...
+// if the access token is sent too it is revoked right away instead of being left
+// valid until it expires
+func (uh *userHandler) SignOut(c *gin.Context) {
+	var tokenInput models.RefreshTokenDto
+	if err := c.ShouldBindJSON(&tokenInput); err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	session, err := uh.sessionRepo.FindByTokenHash(libs.HashRefreshToken(tokenInput.RefreshToken))
+	if err == nil {
+		if err := uh.sessionRepo.RevokeFamily(session.FamilyID); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	const bearerSchema = "Bearer "
+	if authHeader := c.GetHeader("Authorization"); strings.HasPrefix(authHeader, bearerSchema) {
+		payload, err := libs.VerifyToken(authHeader[len(bearerSchema):])
+		if err == nil {
+			if err := uh.revocations.RevokeToken(payload); err != nil {
+				c.Error(err)
+				return
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Successfully signed out",
 	})
 }
 
@@ -97,44 +628,39 @@ func (uh *userHandler) GetUser(c *gin.Context) {
 	userId, _ := xid.FromString(c.Param("userId"))
 	payload := libs.CheckUserId(c, userId)
 	if payload == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Unauthorized",
-		})
+		c.Error(apperrors.Unauthorized("Unauthorized"))
 		return
 	}
 
 	user, err := uh.repo.FindById(userId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"user": user,
+		"user": models.NewSelfUser(&user),
 	})
 }
 
 func (uh *userHandler) GetMultipleUsers(c *gin.Context) {
-	payload := libs.CheckUserRole(c)
-	if payload == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Unauthorized",
-		})
+	pagination, err := libs.NewPagination(c)
+	if err != nil {
+		c.Error(apperrors.InvalidInput(err))
 		return
 	}
 
-	users, err := uh.repo.FindMany()
+	users, err := uh.repo.FindMany(pagination)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(err)
 		return
 	}
 
+	pagination.SetLinkHeader(c)
+
 	c.JSON(http.StatusOK, gin.H{
-		"users": users,
+		"users":      models.NewAdminUsers(users),
+		"pagination": pagination,
 	})
 }
 
@@ -142,24 +668,26 @@ func (uh *userHandler) GetUserWishlist(c *gin.Context) {
 	userId, _ := xid.FromString(c.Param("userId"))
 	payload := libs.CheckUserId(c, userId)
 	if payload == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Unauthorized",
-		})
+		c.Error(apperrors.Unauthorized("Unauthorized"))
 		return
 	}
 
 	var user models.User
 	user.ID = userId
-	products, err := uh.repo.FindUserWishlist(&user)
+	wishlist, err := uh.repo.FindUserWishlist(&user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(err)
 		return
 	}
 
+	// every item tells what has changed since the product was wishlisted
+	items := []models.WishlistItem{}
+	for i := range wishlist {
+		items = append(items, models.NewWishlistItem(&wishlist[i]))
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"wishlist": products,
+		"wishlist": items,
 	})
 }
 
@@ -175,45 +703,65 @@ func (uh *userHandler) UpdateUser(c *gin.Context) {
 	// check if the user id from param matches the user id in jwt payload
 	payload := libs.CheckUserId(c, userId)
 	if payload == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Unauthorized",
-		})
+		c.Error(apperrors.Unauthorized("Unauthorized"))
+		return
+	}
+
+	// the email can be changed here, which would let whoever is impersonating the
+	// user reset the password and take over the account
+	if payload.IsImpersonated() {
+		c.Error(apperrors.Forbidden("Not allowed while impersonating"))
 		return
 	}
 
 	// check if the user with that id exists
 	dbUser, err := uh.repo.FindById(userId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(err)
 		return
 	}
 
-	var userInput models.User
+	var userInput models.UpdateUserDto
 	if err := c.ShouldBindJSON(&userInput); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(apperrors.InvalidInput(err))
 		return
 	}
 
-	// in this scenario, these two fields are not going to be updated
-	// id cannot and must not be updated
-	// password can be updated, but from a different endpoint
-	//
-	// there are other ways to exclude these fields from being updated
-	// imo, this is the simplest way for this particular case
-	userInput.ID = dbUser.ID
-	userInput.Password = dbUser.Password
+	// only the username and the email can be changed here, the password is
+	// changed from a different endpoint and everything else is left as it is
+	if userInput.Username != nil {
+		dbUser.Username = *userInput.Username
+	}
+
+	// a new email address has to be verified again, and since the password can
+	// be reset through it the current password is asked for too (an access token
+	// alone isn't enough to take over the account)
+	emailChanged := userInput.Email != nil && *userInput.Email != dbUser.Email
+	if emailChanged {
+		if userInput.CurrentPassword == "" {
+			c.Error(apperrors.Validation("The request is invalid").
+				WithField("current_password", "required_with", "current_password is required when email is changed"))
+			return
+		}
+		if !checkCurrentPassword(c, uh.loginLimiter, &dbUser, userInput.CurrentPassword) {
+			return
+		}
+
+		dbUser.Email = *userInput.Email
+		dbUser.EmailVerifiedAt = nil
+	}
 
-	if err := uh.repo.UpdateUser(&userInput); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+	if err := uh.repo.UpdateUser(&dbUser); err != nil {
+		c.Error(err)
 		return
 	}
 
+	if emailChanged {
+		if err := uh.mails.sendVerificationEmail(&dbUser); err != nil {
+			log.Println("Error sending verification email:", err)
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "User successfully updated",
 	})
@@ -223,53 +771,54 @@ func (uh *userHandler) UpdatePassword(c *gin.Context) {
 	userId, _ := xid.FromString(c.Param("userId"))
 	payload := libs.CheckUserId(c, userId)
 	if payload == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Unauthorized",
-		})
+		c.Error(apperrors.Unauthorized("Unauthorized"))
+		return
+	}
+
+	// whoever is impersonating the user can't take over the account for good
+	if payload.IsImpersonated() {
+		c.Error(apperrors.Forbidden("Not allowed while impersonating"))
 		return
 	}
 
 	dbUser, err := uh.repo.FindById(userId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(err)
 		return
 	}
 
-	var userInput models.User
-	if err := c.ShouldBindJSON(&userInput); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+	var passwordInput models.UpdatePasswordDto
+	if err := c.ShouldBindJSON(&passwordInput); err != nil {
+		c.Error(apperrors.InvalidInput(err))
 		return
 	}
 
 	// check if the old password is the same as the new password
-	if isTrue := libs.ComparePassword(dbUser.Password, userInput.Password); isTrue {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "The old password cannot be the same as the new password",
-		})
+	if isTrue := libs.ComparePassword(dbUser.Password, passwordInput.Password); isTrue {
+		c.Error(apperrors.Validation("The old password cannot be the same as the new password").
+			WithField("password", "unchanged", "password must be different from the old password"))
 		return
 	}
 
-	if err := libs.HashPassword(&userInput.Password); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+	dbUser.Password = passwordInput.Password
+	if err := libs.HashPassword(&dbUser.Password); err != nil {
+		c.Error(err)
 		return
 	}
 
-	userInput.ID = dbUser.ID
-	if err := uh.repo.UpdatePassword(&userInput); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+	if err := uh.repo.UpdatePassword(&dbUser); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// the tokens issued with the old password must not outlive it
+	if err := uh.revocations.RevokeUser(userId); err != nil {
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Password successfully updated",
+		"message": "Password successfully updated, please sign in again",
 	})
 }
 
@@ -277,18 +826,26 @@ func (uh *userHandler) DeleteUser(c *gin.Context) {
 	userId, _ := xid.FromString(c.Param("userId"))
 	payload := libs.CheckUserId(c, userId)
 	if payload == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Unauthorized",
-		})
+		c.Error(apperrors.Unauthorized("Unauthorized"))
+		return
+	}
+
+	if payload.IsImpersonated() {
+		c.Error(apperrors.Forbidden("Not allowed while impersonating"))
 		return
 	}
 
 	var user models.User
 	user.ID = userId
 	if err := uh.repo.Delete(&user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(err)
+		return
+	}
+
+	// the user's sessions are deleted along with the user, this only makes sure
+	// the cached token generation of the user is dropped right away
+	if err := uh.revocations.RevokeUser(userId); err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -296,3 +853,31 @@ func (uh *userHandler) DeleteUser(c *gin.Context) {
 		"message": "User successfully deleted",
 	})
 }
+
+// revokes every token of the user, this can be done by the users themselves or
+// by whoever is allowed to manage users (e.g. when an account is compromised)
+func (uh *userHandler) SignOutEverywhere(c *gin.Context) {
+	userId, _ := xid.FromString(c.Param("userId"))
+	payload := libs.CheckUserId(c, userId)
+	if payload == nil {
+		payload = libs.CheckPermission(c, models.PermissionUsersWrite)
+	}
+	if payload == nil {
+		c.Error(apperrors.Unauthorized("Unauthorized"))
+		return
+	}
+
+	if _, err := uh.repo.FindById(userId); err != nil {
+		c.Error(apperrors.NotFound("User not found"))
+		return
+	}
+
+	if err := uh.revocations.RevokeUser(userId); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User successfully signed out everywhere",
+	})
+}
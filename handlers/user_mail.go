@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/laluardian/gin-ecommerce-api/libs"
+	"github.com/laluardian/gin-ecommerce-api/mailer"
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/laluardian/gin-ecommerce-api/repositories"
+	"gorm.io/gorm"
+)
+
+// sends the emails that carry the user tokens, it is shared by the handlers
+// that need to send them (e.g. both users and admins can trigger a password reset)
+type userMailer struct {
+	userTokenRepo repositories.UserTokenRepository
+	mailer        mailer.Mailer
+}
+
+func newUserMailer(db *gorm.DB, mailer mailer.Mailer) *userMailer {
+	return &userMailer{
+		repositories.NewUserTokenRepository(db),
+		mailer,
+	}
+}
+
+// issues a new token for the given purpose, the tokens with the same purpose that
+// were issued before are not valid anymore
+func (um *userMailer) issueUserToken(user *models.User, purpose string, ttl time.Duration) (string, error) {
+	token, tokenHash, err := libs.GenerateUserToken(purpose)
+	if err != nil {
+		return "", err
+	}
+
+	if err := um.userTokenRepo.InvalidateByUser(user.ID, purpose); err != nil {
+		return "", err
+	}
+
+	userToken := models.UserToken{
+		Purpose:   purpose,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(ttl),
+		UserID:    user.ID,
+	}
+	if err := um.userTokenRepo.Create(&userToken); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (um *userMailer) sendVerificationEmail(user *models.User) error {
+	token, err := um.issueUserToken(user, models.UserTokenPurposeEmailVerification, libs.EmailVerificationTtl)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\nplease verify your email address with this token: %s\n\nThe token expires in %d hours.",
+		user.Username, token, int(libs.EmailVerificationTtl.Hours()))
+	return um.mailer.Send(user.Email, "Verify your email address", body)
+}
+
+func (um *userMailer) sendPasswordResetEmail(user *models.User) error {
+	token, err := um.issueUserToken(user, models.UserTokenPurposePasswordReset, libs.PasswordResetTtl)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\nyou can reset your password with this token: %s\n\n"+
+		"The token expires in %d minutes. If you didn't ask for it, you can just ignore this email.",
+		user.Username, token, int(libs.PasswordResetTtl.Minutes()))
+	return um.mailer.Send(user.Email, "Reset your password", body)
+}
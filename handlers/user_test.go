@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laluardian/gin-ecommerce-api/libs"
+	"github.com/laluardian/gin-ecommerce-api/middlewares"
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/laluardian/gin-ecommerce-api/ratelimit"
+	"github.com/laluardian/gin-ecommerce-api/repositories"
+	"github.com/laluardian/gin-ecommerce-api/revocation"
+	"github.com/laluardian/gin-ecommerce-api/testutil"
+	"gorm.io/gorm"
+)
+
+type sentMail struct {
+	to, subject, body string
+}
+
+// keeps every email instead of sending it
+type recordingMailer struct {
+	mails chan sentMail
+}
+
+func newRecordingMailer() *recordingMailer {
+	return &recordingMailer{make(chan sentMail, 16)}
+}
+
+func (rm *recordingMailer) Send(to, subject, body string) error {
+	rm.mails <- sentMail{to, subject, body}
+	return nil
+}
+
+// waits for the next email, some of them are sent in the background
+func (rm *recordingMailer) next(t *testing.T) sentMail {
+	t.Helper()
+
+	select {
+	case mail := <-rm.mails:
+		return mail
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email has been sent")
+		return sentMail{}
+	}
+}
+
+func (rm *recordingMailer) expectNone(t *testing.T) {
+	t.Helper()
+
+	select {
+	case mail := <-rm.mails:
+		t.Fatalf("unexpected email to %s: %q", mail.to, mail.subject)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+var mailTokenRegex = regexp.MustCompile(`token: (\S+)`)
+
+func tokenFromMail(t *testing.T, mail sentMail) string {
+	t.Helper()
+
+	match := mailTokenRegex.FindStringSubmatch(mail.body)
+	if match == nil {
+		t.Fatalf("no token in the email: %q", mail.body)
+	}
+
+	return match[1]
+}
+
+func newPasswordRouter(t *testing.T) (*gin.Engine, *gorm.DB, *recordingMailer) {
+	t.Helper()
+	t.Setenv("USER_TOKEN_SECRET", "test-user-token-secret")
+	gin.SetMode(gin.TestMode)
+
+	db := testutil.OpenDB(t)
+	mails := newRecordingMailer()
+	loginLimiter := ratelimit.NewLoginLimiter(ratelimit.NewMemoryStore(), ratelimit.AccountPolicyFromEnv(), ratelimit.IpPolicyFromEnv())
+	userHandler := NewUserHandler(db, revocation.NewRevocationStore(db), mails, loginLimiter)
+
+	r := gin.New()
+	r.Use(middlewares.ErrorHandler())
+	r.POST("/password/forgot", userHandler.ForgotPassword)
+	r.POST("/password/reset", userHandler.ResetPassword)
+	return r, db, mails
+}
+
+func TestForgotPassword(t *testing.T) {
+	r, db, mails := newPasswordRouter(t)
+	user := testutil.CreateUser(t, db, "alice", "old-password")
+
+	res := testutil.DoJSON(r, http.MethodPost, "/password/forgot", gin.H{"email": user.Email})
+	if res.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", res.Code, http.StatusAccepted)
+	}
+
+	mail := mails.next(t)
+	if mail.to != user.Email || mail.subject != "Reset your password" {
+		t.Fatalf("email to %s with subject %q, want a password reset to %s", mail.to, mail.subject, user.Email)
+	}
+	tokenFromMail(t, mail)
+}
+
+// the response must not tell whether the email is registered
+func TestForgotPasswordUnknownEmail(t *testing.T) {
+	r, _, mails := newPasswordRouter(t)
+
+	res := testutil.DoJSON(r, http.MethodPost, "/password/forgot", gin.H{"email": "nobody@example.com"})
+	if res.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", res.Code, http.StatusAccepted)
+	}
+
+	mails.expectNone(t)
+}
+
+func TestResetPassword(t *testing.T) {
+	r, db, mails := newPasswordRouter(t)
+	user := testutil.CreateUser(t, db, "alice", "old-password")
+
+	testutil.DoJSON(r, http.MethodPost, "/password/forgot", gin.H{"email": user.Email})
+	token := tokenFromMail(t, mails.next(t))
+
+	res := testutil.DoJSON(r, http.MethodPost, "/password/reset", gin.H{"token": token, "password": "new-password"})
+	if res.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", res.Code, http.StatusOK, res.Body)
+	}
+
+	dbUser, err := repositories.NewUserRepository(db).FindById(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !libs.ComparePassword(dbUser.Password, "new-password") {
+		t.Fatal("the password hasn't been changed")
+	}
+	if dbUser.TokenGeneration == user.TokenGeneration {
+		t.Fatal("the user's tokens haven't been revoked")
+	}
+
+	// the token is single-use
+	res = testutil.DoJSON(r, http.MethodPost, "/password/reset", gin.H{"token": token, "password": "another-password"})
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("reused token: status = %d, want %d", res.Code, http.StatusBadRequest)
+	}
+}
+
+// only the latest token that has been sent is valid
+func TestResetPasswordWithReplacedToken(t *testing.T) {
+	r, db, mails := newPasswordRouter(t)
+	user := testutil.CreateUser(t, db, "alice", "old-password")
+
+	testutil.DoJSON(r, http.MethodPost, "/password/forgot", gin.H{"email": user.Email})
+	first := tokenFromMail(t, mails.next(t))
+	testutil.DoJSON(r, http.MethodPost, "/password/forgot", gin.H{"email": user.Email})
+	second := tokenFromMail(t, mails.next(t))
+
+	res := testutil.DoJSON(r, http.MethodPost, "/password/reset", gin.H{"token": first, "password": "new-password"})
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("replaced token: status = %d, want %d", res.Code, http.StatusBadRequest)
+	}
+
+	res = testutil.DoJSON(r, http.MethodPost, "/password/reset", gin.H{"token": second, "password": "new-password"})
+	if res.Code != http.StatusOK {
+		t.Fatalf("latest token: status = %d, want %d: %s", res.Code, http.StatusOK, res.Body)
+	}
+}
+
+func TestResetPasswordInvalidTokens(t *testing.T) {
+	r, db, mails := newPasswordRouter(t)
+	user := testutil.CreateUser(t, db, "alice", "old-password")
+
+	expired, err := newUserMailer(db, mails).issueUserToken(&user, models.UserTokenPurposePasswordReset, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a valid token for verifying the email can't be used to reset the password
+	verification, err := newUserMailer(db, mails).issueUserToken(&user, models.UserTokenPurposeEmailVerification, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"expired", expired},
+		{"other purpose", verification},
+		{"forged", "Zm9yZ2Vk.Zm9yZ2Vk"},
+		{"malformed", "not-a-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := testutil.DoJSON(r, http.MethodPost, "/password/reset", gin.H{"token": tt.token, "password": "new-password"})
+			if res.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", res.Code, http.StatusBadRequest)
+			}
+		})
+	}
+
+	dbUser, err := repositories.NewUserRepository(db).FindById(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !libs.ComparePassword(dbUser.Password, "old-password") {
+		t.Fatal("the password has been changed with an invalid token")
+	}
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laluardian/gin-ecommerce-api/apperrors"
+	"github.com/laluardian/gin-ecommerce-api/libs"
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/laluardian/gin-ecommerce-api/payments"
+	"github.com/laluardian/gin-ecommerce-api/repositories"
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+// the events are small json documents, anything bigger is rejected unread
+const maxWebhookBodySize = 64 << 10
+
+// the payment status every webhook event type moves a payment to
+var paymentEventStatuses = map[string]string{
+	payments.EventPaymentAuthorized: models.PaymentStatusAuthorized,
+	payments.EventPaymentCaptured:   models.PaymentStatusCaptured,
+	payments.EventPaymentRefunded:   models.PaymentStatusRefunded,
+	payments.EventPaymentFailed:     models.PaymentStatusFailed,
+}
+
+// and the order status that goes along with some of the payment statuses
+var paymentOrderStatuses = map[string]string{
+	models.PaymentStatusCaptured: models.OrderStatusPaid,
+	models.PaymentStatusRefunded: models.OrderStatusRefunded,
+}
+
+type WebhookHandler interface {
+	ReceivePaymentEvent(c *gin.Context)
+	GetPaymentEvents(c *gin.Context)
+	ReprocessPaymentEvent(c *gin.Context)
+}
+
+type webhookHandler struct {
+	db       *gorm.DB
+	repo     repositories.PaymentEventRepository
+	provider payments.PaymentProvider
+}
+
+func NewWebhookHandler(db *gorm.DB, provider payments.PaymentProvider) WebhookHandler {
+	return &webhookHandler{
+		db,
+		repositories.NewPaymentEventRepository(db),
+		provider,
+	}
+}
+
+// this route is public (it is called by the payment provider, not by our users)
+// so every request must carry a valid signature, only the verified events are
+// stored since storing unverified ones would let anyone squat on event ids
+func (wh *webhookHandler) ReceivePaymentEvent(c *gin.Context) {
+	// anyone can send anything here, so the body is read only up to a limit no
+	// real event comes close to
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBodySize))
+	if err != nil {
+		appErr := apperrors.New(http.StatusRequestEntityTooLarge, apperrors.CodeValidation, "The request body is too large")
+		appErr.Err = err
+		c.Error(appErr)
+		return
+	}
+
+	webhookEvent, err := wh.provider.VerifyWebhook(c.Request.Header, body)
+	if err != nil {
+		if errors.Is(err, payments.ErrInvalidSignature) || errors.Is(err, payments.ErrStaleWebhook) {
+			c.Error(apperrors.Unauthorized(err.Error()))
+			return
+		}
+
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	// the id is what the replays are told apart by, an event without one would
+	// make every later one look like a replay of it
+	if webhookEvent.ID == "" || webhookEvent.Reference == "" {
+		c.Error(apperrors.Validation("The event must have an id and a reference"))
+		return
+	}
+
+	event := models.PaymentEvent{
+		Provider:  wh.provider.Name(),
+		EventID:   webhookEvent.ID,
+		Type:      webhookEvent.Type,
+		Reference: webhookEvent.Reference,
+		Payload:   string(body),
+		Signature: c.GetHeader(payments.SignatureHeader),
+		Timestamp: webhookEvent.Timestamp,
+		Status:    models.PaymentEventStatusReceived,
+	}
+
+	// the event is stored and applied in one transaction, so when applying it
+	// fails (e.g. the database is gone for a moment) it isn't stored either and
+	// the provider's retry applies it again
+	var created bool
+	err = wh.db.Transaction(func(tx *gorm.DB) error {
+		created, err = repositories.NewPaymentEventRepository(tx).Create(&event)
+		if err != nil || !created {
+			return err
+		}
+
+		return wh.process(tx, &event, webhookEvent)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// the event has already been received before (the provider retried it or
+	// someone is replaying it), it is acknowledged but never applied twice
+	if !created {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Event already received",
+		})
+		return
+	}
+
+	// the provider only needs to know that the event has been received, whether
+	// it could be applied or not is recorded in the event itself
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Event successfully received",
+		"status":  event.Status,
+	})
+}
+
+func (wh *webhookHandler) GetPaymentEvents(c *gin.Context) {
+	pagination, err := libs.NewPagination(c)
+	if err != nil {
+		c.Error(apperrors.InvalidInput(err))
+		return
+	}
+
+	events, err := wh.repo.FindMany(pagination)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	pagination.SetLinkHeader(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"events":     models.NewPaymentEventDetails(events),
+		"pagination": pagination,
+	})
+}
+
+// applies a stored event again, e.g. when it failed because the payment it
+// refers to didn't exist yet... the stored payload is verified again with the
+// stored signature so a tampered row can't be used to change a payment
+func (wh *webhookHandler) ReprocessPaymentEvent(c *gin.Context) {
+	eventId, _ := xid.FromString(c.Param("eventId"))
+	event, err := wh.repo.FindById(eventId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if event.Status == models.PaymentEventStatusProcessed {
+		c.Error(apperrors.Conflict("Event has already been processed"))
+		return
+	}
+
+	header := make(http.Header)
+	header.Set(payments.SignatureHeader, event.Signature)
+	header.Set(payments.TimestampHeader, fmt.Sprint(event.Timestamp.Unix()))
+
+	// the signature is checked before the timestamp, so a stale error still means
+	// that the signature itself is valid
+	webhookEvent, err := wh.provider.VerifyWebhook(header, []byte(event.Payload))
+	if err != nil && !errors.Is(err, payments.ErrStaleWebhook) {
+		appErr := apperrors.Conflict("The stored event can't be verified")
+		appErr.Err = err
+		c.Error(appErr)
+		return
+	}
+	if webhookEvent == nil {
+		// the timestamp window only matters for incoming requests, stored events
+		// are expected to be old by the time they are reprocessed
+		webhookEvent = &payments.WebhookEvent{
+			ID:        event.EventID,
+			Type:      event.Type,
+			Reference: event.Reference,
+		}
+	}
+
+	err = wh.db.Transaction(func(tx *gorm.DB) error {
+		return wh.process(tx, &event, webhookEvent)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Event successfully reprocessed",
+		"event":   models.NewPaymentEventDetail(&event),
+	})
+}
+
+// records the payment status reported by the event against the payment it
+// refers to, applying the same event more than once doesn't change anything...
+// the event only ends up failed when it can't be applied as it is (e.g. its
+// payment doesn't exist yet), any other error is returned so the transaction
+// is rolled back
+func (wh *webhookHandler) process(tx *gorm.DB, event *models.PaymentEvent, webhookEvent *payments.WebhookEvent) (err error) {
+	event.Status, event.Error, err = wh.apply(tx, webhookEvent)
+	if err != nil {
+		return err
+	}
+
+	if event.Status == models.PaymentEventStatusProcessed {
+		now := time.Now()
+		event.ProcessedAt = &now
+	}
+
+	return repositories.NewPaymentEventRepository(tx).Update(event)
+}
+
+func (wh *webhookHandler) apply(tx *gorm.DB, webhookEvent *payments.WebhookEvent) (string, string, error) {
+	status, ok := paymentEventStatuses[webhookEvent.Type]
+	if !ok {
+		return models.PaymentEventStatusIgnored, "unknown event type " + webhookEvent.Type, nil
+	}
+
+	paymentRepo := repositories.NewPaymentRepository(tx)
+	payment, err := paymentRepo.FindByReference(wh.provider.Name(), webhookEvent.Reference)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.PaymentEventStatusFailed, err.Error(), nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	// the payment is already in the reported status (e.g. it was set when the
+	// provider responded to our own request), there is nothing left to do
+	if payment.Status == status {
+		return models.PaymentEventStatusProcessed, "", nil
+	}
+
+	if !payment.CanTransitionTo(status) {
+		return models.PaymentEventStatusIgnored, "payment cannot move from " + payment.Status + " to " + status, nil
+	}
+
+	payment.Status = status
+	payment.FailureReason = webhookEvent.Reason
+	if err := paymentRepo.Update(&payment); err != nil {
+		return "", "", err
+	}
+
+	if orderStatus, ok := paymentOrderStatuses[status]; ok {
+		order := models.Order{ID: payment.OrderID}
+		if err := repositories.NewOrderRepository(tx).UpdateStatus(&order, orderStatus); err != nil {
+			return "", "", err
+		}
+	}
+
+	return models.PaymentEventStatusProcessed, "", nil
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laluardian/gin-ecommerce-api/middlewares"
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/laluardian/gin-ecommerce-api/payments"
+	"github.com/laluardian/gin-ecommerce-api/repositories"
+	"github.com/laluardian/gin-ecommerce-api/testutil"
+	"gorm.io/gorm"
+)
+
+const testWebhookSecret = "test-webhook-secret"
+
+func newWebhookRouter(t *testing.T, db *gorm.DB) *gin.Engine {
+	t.Helper()
+	t.Setenv("PAYMENT_WEBHOOK_SECRET", testWebhookSecret)
+	gin.SetMode(gin.TestMode)
+
+	provider, err := payments.NewFakeProvider(payments.FakeBehaviorSucceed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.Use(middlewares.ErrorHandler())
+	r.POST("/webhooks/payments", NewWebhookHandler(db, provider).ReceivePaymentEvent)
+	return r
+}
+
+// signs the body the way the provider does, at the given time
+func sendWebhook(r http.Handler, body []byte, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/payments", bytes.NewReader(body))
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	return res
+}
+
+func webhookBody(t *testing.T, event payments.WebhookEvent) []byte {
+	t.Helper()
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+// the requests that are turned away before the database is ever touched
+func TestReceivePaymentEventRejected(t *testing.T) {
+	r := newWebhookRouter(t, nil)
+	secret := []byte(testWebhookSecret)
+	event := payments.WebhookEvent{ID: "evt_1", Type: payments.EventPaymentCaptured, Reference: "fake_1"}
+	body := webhookBody(t, event)
+
+	tests := []struct {
+		name   string
+		body   []byte
+		header http.Header
+		want   int
+	}{
+		{"unsigned", body, http.Header{}, http.StatusUnauthorized},
+		{"wrong secret", body, payments.SignWebhook([]byte("other-secret"), body, time.Now()), http.StatusUnauthorized},
+		{"stale", body, payments.SignWebhook(secret, body, time.Now().Add(-time.Hour)), http.StatusUnauthorized},
+		{
+			"without an id",
+			webhookBody(t, payments.WebhookEvent{Type: event.Type, Reference: event.Reference}),
+			nil,
+			http.StatusBadRequest,
+		},
+		{
+			"without a reference",
+			webhookBody(t, payments.WebhookEvent{ID: event.ID, Type: event.Type}),
+			nil,
+			http.StatusBadRequest,
+		},
+		{
+			"too large",
+			[]byte(`{"id":"evt_1","padding":"` + strings.Repeat("x", maxWebhookBodySize) + `"}`),
+			nil,
+			http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := tt.header
+			if header == nil {
+				header = payments.SignWebhook(secret, tt.body, time.Now())
+			}
+
+			res := sendWebhook(r, tt.body, header)
+			if res.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", res.Code, tt.want, res.Body)
+			}
+		})
+	}
+}
+
+// an event is applied once, the provider's retries (and anyone replaying a
+// captured request) are acknowledged without being applied again
+func TestReceivePaymentEventReplay(t *testing.T) {
+	db := testutil.OpenDB(t)
+	r := newWebhookRouter(t, db)
+	secret := []byte(testWebhookSecret)
+
+	user := testutil.CreateUser(t, db, "alice", "password")
+	order := models.Order{UserID: user.ID, Status: models.OrderStatusPending, TotalPrice: 1000}
+	if err := repositories.NewOrderRepository(db).Create(&order); err != nil {
+		t.Fatal(err)
+	}
+	payment := models.Payment{
+		Provider:  payments.FakeProviderName,
+		Reference: "fake_1",
+		Amount:    1000,
+		Status:    models.PaymentStatusAuthorized,
+		OrderID:   order.ID,
+	}
+	if err := db.Create(&payment).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	body := webhookBody(t, payments.WebhookEvent{ID: "evt_1", Type: payments.EventPaymentCaptured, Reference: payment.Reference})
+	header := payments.SignWebhook(secret, body, time.Now())
+
+	res := sendWebhook(r, body, header)
+	if res.Code != http.StatusOK || !strings.Contains(res.Body.String(), models.PaymentEventStatusProcessed) {
+		t.Fatalf("first delivery: status = %d: %s", res.Code, res.Body)
+	}
+
+	// the same request again, and the provider's retry that is signed anew
+	for _, replayHeader := range []http.Header{header, payments.SignWebhook(secret, body, time.Now())} {
+		res = sendWebhook(r, body, replayHeader)
+		if res.Code != http.StatusOK || !strings.Contains(res.Body.String(), "already received") {
+			t.Fatalf("replay: status = %d: %s", res.Code, res.Body)
+		}
+	}
+
+	var events int64
+	if err := db.Model(&models.PaymentEvent{}).Count(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+	if events != 1 {
+		t.Fatalf("stored events = %d, want 1", events)
+	}
+
+	dbPayment, err := repositories.NewPaymentRepository(db).FindByReference(payment.Provider, payment.Reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dbPayment.Status != models.PaymentStatusCaptured {
+		t.Fatalf("payment status = %s, want %s", dbPayment.Status, models.PaymentStatusCaptured)
+	}
+
+	dbOrder, err := repositories.NewOrderRepository(db).FindById(order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dbOrder.Status != models.OrderStatusPaid {
+		t.Fatalf("order status = %s, want %s", dbOrder.Status, models.OrderStatusPaid)
+	}
+}
@@ -0,0 +1,306 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/laluardian/gin-ecommerce-api/repositories"
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrReservationNotFound = errors.New("reservation not found")
+	ErrReservationExpired  = errors.New("reservation is expired")
+	ErrReservationClosed   = errors.New("reservation is already committed or released")
+)
+
+const (
+	defaultReservationTtl = 15 * time.Minute
+	sweeperBatchSize      = 100
+)
+
+// a single line of an insufficient stock error, it tells the client how many
+// units of the product were requested and how many are actually available
+type InsufficientStockLine struct {
+	ProductID xid.ID `json:"product_id"`
+	Requested uint32 `json:"requested"`
+	Available uint32 `json:"available"`
+	Error     string `json:"error"`
+}
+
+type InsufficientStockError struct {
+	Lines []InsufficientStockLine
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for %d product(s)", len(e.Lines))
+}
+
+// adds up the quantities without wrapping around, a sum that doesn't fit is more
+// than any product has in stock anyway
+func addQuantities(a, b uint32) uint32 {
+	if sum := a + b; sum >= a {
+		return sum
+	}
+
+	return math.MaxUint32
+}
+
+type InventoryService interface {
+	Reserve(userId xid.ID, items []models.ReservationItem) (models.Reservation, error)
+	Find(userId, reservationId xid.ID) (models.Reservation, error)
+	Commit(userId, reservationId xid.ID, order *models.Order) error
+	Release(userId, reservationId xid.ID) error
+	ReleaseExpired() (int, error)
+	RunSweeper(ctx context.Context, interval time.Duration)
+}
+
+type inventoryService struct {
+	db *gorm.DB
+}
+
+func NewInventoryService(db *gorm.DB) InventoryService {
+	return &inventoryService{db}
+}
+
+// reserves the stock of every item in a single transaction, either all of the
+// items are reserved or none of them is... the product rows are locked with
+// SELECT ... FOR UPDATE so two concurrent reservations of the last unit of a
+// product can never both succeed
+func (is *inventoryService) Reserve(userId xid.ID, items []models.ReservationItem) (models.Reservation, error) {
+	var reservation models.Reservation
+
+	// the same product might be sent more than once, in that case the quantities are summed up
+	quantities := make(map[xid.ID]uint32)
+	var productIds []xid.ID
+	for _, item := range items {
+		if _, ok := quantities[item.ProductID]; !ok {
+			productIds = append(productIds, item.ProductID)
+		}
+		quantities[item.ProductID] = addQuantities(quantities[item.ProductID], item.Quantity)
+	}
+
+	err := is.db.Transaction(func(tx *gorm.DB) error {
+		productRepo := repositories.NewProductRepository(tx)
+		reservationRepo := repositories.NewReservationRepository(tx)
+
+		products, err := productRepo.FindByIdsForUpdate(productIds)
+		if err != nil {
+			return err
+		}
+
+		available := make(map[xid.ID]uint32)
+		found := make(map[xid.ID]bool)
+		for _, product := range products {
+			available[product.ID] = product.Quantity
+			found[product.ID] = true
+		}
+
+		var insufficient []InsufficientStockLine
+		for _, productId := range productIds {
+			line := InsufficientStockLine{
+				ProductID: productId,
+				Requested: quantities[productId],
+				Available: available[productId],
+			}
+
+			switch {
+			case !found[productId]:
+				line.Error = "product not found"
+			case line.Requested > line.Available:
+				line.Error = "insufficient stock"
+			default:
+				continue
+			}
+
+			insufficient = append(insufficient, line)
+		}
+
+		if len(insufficient) > 0 {
+			return &InsufficientStockError{insufficient}
+		}
+
+		reservation.UserID = userId
+		reservation.Status = models.ReservationStatusPending
+		reservation.ExpiresAt = time.Now().Add(reservationTtl())
+
+		for _, productId := range productIds {
+			if err := productRepo.DecrementQuantity(productId, quantities[productId]); err != nil {
+				return err
+			}
+
+			reservation.Items = append(reservation.Items, models.ReservationItem{
+				ProductID: productId,
+				Quantity:  quantities[productId],
+			})
+		}
+
+		return reservationRepo.Create(&reservation)
+	})
+
+	return reservation, err
+}
+
+func (is *inventoryService) Find(userId, reservationId xid.ID) (models.Reservation, error) {
+	reservationRepo := repositories.NewReservationRepository(is.db)
+
+	reservation, err := reservationRepo.FindByIds(userId, reservationId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return reservation, ErrReservationNotFound
+	}
+
+	return reservation, err
+}
+
+// a committed reservation keeps the stock taken from the products for good,
+// it can't be released anymore... a reservation is only ever committed into an
+// order, the order is created in the same transaction so there is never an
+// order without its stock nor stock taken without an order
+func (is *inventoryService) Commit(userId, reservationId xid.ID, order *models.Order) error {
+	return is.db.Transaction(func(tx *gorm.DB) error {
+		reservationRepo := repositories.NewReservationRepository(tx)
+
+		reservation, err := reservationRepo.FindByIdsForUpdate(userId, reservationId)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrReservationNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if reservation.Status != models.ReservationStatusPending {
+			return ErrReservationClosed
+		}
+
+		// the sweeper might not have released it yet, but an expired reservation is
+		// treated as if it has already been released
+		if time.Now().After(reservation.ExpiresAt) {
+			return ErrReservationExpired
+		}
+
+		if err := repositories.NewOrderRepository(tx).Create(order); err != nil {
+			return err
+		}
+
+		return reservationRepo.UpdateStatus(&reservation, models.ReservationStatusCommitted)
+	})
+}
+
+func (is *inventoryService) Release(userId, reservationId xid.ID) error {
+	return is.db.Transaction(func(tx *gorm.DB) error {
+		reservationRepo := repositories.NewReservationRepository(tx)
+
+		reservation, err := reservationRepo.FindByIdsForUpdate(userId, reservationId)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrReservationNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		switch reservation.Status {
+		case models.ReservationStatusReleased:
+			return nil
+		case models.ReservationStatusCommitted:
+			return ErrReservationClosed
+		}
+
+		return release(tx, &reservation)
+	})
+}
+
+// releases the expired reservations in batches and returns how many of them are released
+func (is *inventoryService) ReleaseExpired() (int, error) {
+	released := 0
+
+	for {
+		var batch int
+		err := is.db.Transaction(func(tx *gorm.DB) error {
+			reservationRepo := repositories.NewReservationRepository(tx)
+
+			reservations, err := reservationRepo.FindExpiredForUpdate(time.Now(), sweeperBatchSize)
+			if err != nil {
+				return err
+			}
+
+			for i := range reservations {
+				if err := release(tx, &reservations[i]); err != nil {
+					return err
+				}
+			}
+
+			batch = len(reservations)
+			return nil
+		})
+		if err != nil {
+			return released, err
+		}
+
+		released += batch
+		if batch < sweeperBatchSize {
+			return released, nil
+		}
+	}
+}
+
+// periodically releases the expired reservations until the context is cancelled,
+// this is meant to be run in its own goroutine
+func (is *inventoryService) RunSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			released, err := is.ReleaseExpired()
+			if err != nil {
+				log.Println("Error releasing expired reservations:", err)
+			}
+			if released > 0 {
+				log.Printf("Released %d expired reservation(s)\n", released)
+			}
+		}
+	}
+}
+
+// gives the reserved stock back to the products, the reservation must already
+// be locked by the given transaction
+func release(tx *gorm.DB, reservation *models.Reservation) error {
+	productRepo := repositories.NewProductRepository(tx)
+	reservationRepo := repositories.NewReservationRepository(tx)
+
+	// the products are updated in the order of their ids just like the way they
+	// are locked in Reserve, otherwise the two could deadlock each other
+	items := append([]models.ReservationItem(nil), reservation.Items...)
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ProductID.Compare(items[j].ProductID) < 0
+	})
+
+	for _, item := range items {
+		if err := productRepo.IncrementQuantity(item.ProductID, item.Quantity); err != nil {
+			return err
+		}
+	}
+
+	return reservationRepo.UpdateStatus(reservation, models.ReservationStatusReleased)
+}
+
+// the reservation ttl can be configured with the RESERVATION_TTL env var (e.g. "10m")
+func reservationTtl() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("RESERVATION_TTL"))
+	if err != nil || ttl <= 0 {
+		return defaultReservationTtl
+	}
+
+	return ttl
+}
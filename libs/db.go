@@ -4,24 +4,43 @@ import (
 	"fmt"
 	"log"
 
+	"github.com/laluardian/gin-ecommerce-api/migrations"
 	"github.com/laluardian/gin-ecommerce-api/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
+// connects to the database and refuses to go on when its schema is behind, the
+// schema is never changed on startup... the migrations are applied with the
+// migrate command (see migrate.go) before the new version of the app is started
 func InitDB(dsn string) *gorm.DB {
+	db := OpenDB(dsn)
+
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		log.Fatal("Error loading the migrations: ", err)
+	}
+
+	if err := migrator.Check(); err != nil {
+		log.Fatal("Error checking the database schema: ", err, ", run the migrations with `go run . migrate up` first")
+	}
+
+	fmt.Println("Connected to database")
+	return db
+}
+
+// connects to the database without checking its schema, only the migrate command
+// should use it directly
+func OpenDB(dsn string) *gorm.DB {
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
 	if err != nil {
 		log.Fatal("Error connecting to database")
 	}
 
-	db.AutoMigrate(
-		&models.User{},
-		&models.Product{},
-		&models.Address{},
-		&models.Category{},
-	)
+	// the wishlist join table has some extra columns so it needs to be set up
+	// manually, both sides of the relationship must use the same join model
+	db.SetupJoinTable(&models.User{}, "Wishlist", &models.WishlistProduct{})
+	db.SetupJoinTable(&models.Product{}, "WishlistedBy", &models.WishlistProduct{})
 
-	fmt.Println("Connected to database")
 	return db
 }
@@ -0,0 +1,136 @@
+package libs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// the id is always there, whatever fields are asked for
+const idField = "id"
+
+// the fields and the expansions of a resource the clients are allowed to ask for,
+// the names are the json names of the response
+type FieldSetSchema struct {
+	Fields     []string
+	Expansions []string
+}
+
+// a sparse fieldset (the fields param) and the related resources to expand (the
+// expand param), e.g. ?fields=name,price&expand=categories... every field is
+// returned when no fields are asked for, but nothing is expanded unless asked
+type FieldSet struct {
+	Fields []string `json:"fields,omitempty"`
+	Expand []string `json:"expand,omitempty"`
+}
+
+// both params are comma separated lists, every name in them must be in the schema
+func NewFieldSet(c *gin.Context, schema *FieldSetSchema) (*FieldSet, error) {
+	fields, err := parseFieldList(c.Query("fields"), "field", schema.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	expand, err := parseFieldList(c.Query("expand"), "expansion", schema.Expansions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FieldSet{Fields: fields, Expand: expand}, nil
+}
+
+func parseFieldList(param, kind string, allowed []string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(param, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || containsString(names, name) {
+			continue
+		}
+
+		if !containsString(allowed, name) {
+			return nil, fmt.Errorf("unknown %s %q, it must be one of %s", kind, name, strings.Join(allowed, ", "))
+		}
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// a nil fieldset has every field and expands nothing
+func (fs *FieldSet) HasField(name string) bool {
+	return fs == nil || len(fs.Fields) == 0 || name == idField || containsString(fs.Fields, name)
+}
+
+func (fs *FieldSet) Expands(name string) bool {
+	return fs != nil && containsString(fs.Expand, name)
+}
+
+// the columns to select for the fields that have been asked for, columnsByField
+// tells which columns (or expressions) each field is made of
+func (fs *FieldSet) Columns(columnsByField map[string][]string, fields []string) []string {
+	var columns []string
+	for _, field := range fields {
+		if !fs.HasField(field) {
+			continue
+		}
+
+		for _, column := range columnsByField[field] {
+			if !containsString(columns, column) {
+				columns = append(columns, column)
+			}
+		}
+	}
+
+	return columns
+}
+
+// drops the fields that haven't been asked for from a response (an object or a
+// list of objects), the expanded ones are always kept... the response is only
+// made of our own dtos so it can't fail to be marshalled, if it does anyway it
+// is returned as it is
+func (fs *FieldSet) Filter(v interface{}) interface{} {
+	if fs == nil || len(fs.Fields) == 0 {
+		return v
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+
+	var list []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &list); err == nil {
+		for _, object := range list {
+			fs.filterObject(object)
+		}
+		return list
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return v
+	}
+
+	fs.filterObject(object)
+	return object
+}
+
+func (fs *FieldSet) filterObject(object map[string]json.RawMessage) {
+	for key := range object {
+		if !fs.HasField(key) && !fs.Expands(key) {
+			delete(object, key)
+		}
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -2,7 +2,6 @@ package libs
 
 import (
 	"errors"
-	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -18,47 +17,127 @@ var (
 
 const JwtPayloadKey = "jwt_payload"
 
+// access tokens are short-lived, they are renewed with refresh tokens
+const defaultAccessTokenTtl = 15 * time.Minute
+
+// impersonation tokens are even shorter-lived and they can't be renewed
+const ImpersonationTtl = 5 * time.Minute
+
+// a token with a scope can only be used for that one thing, e.g. a token with the
+// mfa_pending scope can only be exchanged for a real one with a totp code
 const (
-	RoleAdmin = "admin"
-	RoleUser  = "user"
+	ScopeMfaPending = "mfa_pending"
+	MfaPendingTtl   = 5 * time.Minute
 )
 
+// the registered time claims (iat and exp) are numeric dates, i.e. the seconds
+// since the epoch, otherwise no other jwt library would understand them
 type JwtPayload struct {
-	Sub      xid.ID    `json:"sub"`
-	Username string    `json:"username"`
-	Role     string    `json:"role"`
-	Iat      time.Time `json:"iat"`
-	Exp      time.Time `json:"exp"`
+	Jti           string           `json:"jti"`
+	Sub           xid.ID           `json:"sub"`
+	Username      string           `json:"username"`
+	Roles         []string         `json:"roles,omitempty"`
+	Permissions   []string         `json:"permissions,omitempty"`
+	Gen           uint             `json:"gen"`
+	EmailVerified bool             `json:"email_verified"`
+	Mfa           bool             `json:"mfa"`
+	Scope         string           `json:"scope,omitempty"`
+	Act           *JwtActor        `json:"act,omitempty"`
+	Iat           *jwt.NumericDate `json:"iat"`
+	Exp           *jwt.NumericDate `json:"exp"`
 }
 
-func newJwtPayload(user *models.User) *JwtPayload {
-	role := RoleUser
-	if user.IsAdmin {
-		role = RoleAdmin
-	}
+// the actor claim (RFC 8693) of an impersonation token, it is who is actually
+// acting as the user the token is for
+type JwtActor struct {
+	Sub      xid.ID `json:"sub"`
+	Username string `json:"username"`
+}
 
+// the permissions are embedded in the token so they don't have to be looked up on
+// every request, the user's roles must be loaded along with their permissions
+func newJwtPayload(user *models.User) *JwtPayload {
+	now := time.Now()
 	return &JwtPayload{
-		Sub:      user.ID,
-		Username: user.Username,
-		Role:     role,
-		Iat:      time.Now(),
-		Exp:      time.Now().Add(time.Hour * 24),
+		Jti:           xid.New().String(),
+		Sub:           user.ID,
+		Username:      user.Username,
+		Roles:         user.RoleNames(),
+		Permissions:   user.PermissionNames(),
+		Gen:           user.TokenGeneration,
+		EmailVerified: user.EmailVerifiedAt != nil,
+		Iat:           jwt.NewNumericDate(now),
+		Exp:           jwt.NewNumericDate(now.Add(AccessTokenTtl())),
 	}
 }
 
+// the access token ttl can be configured with the ACCESS_TOKEN_TTL env var (e.g. "15m")
+func AccessTokenTtl() time.Duration {
+	return durationFromEnv("ACCESS_TOKEN_TTL", defaultAccessTokenTtl)
+}
+
+// a token without an expiry is never valid
 func (p *JwtPayload) Valid() error {
-	if time.Now().After(p.Exp) {
+	if p.Exp == nil {
+		return ErrInvalidToken
+	}
+
+	if time.Now().After(p.Exp.Time) {
 		return ErrExpiredToken
 	}
 
 	return nil
 }
 
-func GenerateToken(user *models.User) (string, error) {
+// the mfa flag tells whether the user has signed in with a second factor
+func GenerateToken(user *models.User, mfa bool) (string, error) {
+	payload := newJwtPayload(user)
+	payload.Mfa = mfa
+	return signToken(payload)
+}
+
+// issues the token that is handed out when the password is right but the second
+// factor is still missing, it carries no permissions at all
+func GenerateMfaPendingToken(user *models.User) (string, error) {
+	now := time.Now()
+	return signToken(&JwtPayload{
+		Jti:   xid.New().String(),
+		Sub:   user.ID,
+		Gen:   user.TokenGeneration,
+		Scope: ScopeMfaPending,
+		Iat:   jwt.NewNumericDate(now),
+		Exp:   jwt.NewNumericDate(now.Add(MfaPendingTtl)),
+	})
+}
+
+// issues a token that lets the actor act as the user for a few minutes, the token
+// is marked with the act claim so it can be told apart from the user's own tokens
+func GenerateImpersonationToken(user, actor *models.User) (string, *JwtPayload, error) {
 	payload := newJwtPayload(user)
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
+	payload.Act = &JwtActor{Sub: actor.ID, Username: actor.Username}
+	payload.Exp = jwt.NewNumericDate(payload.Iat.Add(ImpersonationTtl))
+
+	token, err := signToken(payload)
+	return token, payload, err
+}
+
+func (p *JwtPayload) IsImpersonated() bool {
+	return p.Act != nil
+}
+
+func signToken(payload *JwtPayload) (string, error) {
+	keySet, err := getJwtKeys()
+	if err != nil {
+		return "", err
+	}
+
+	jwtToken := jwt.NewWithClaims(keySet.signing.method, payload)
+	// the kid tells the verifiers which key the token has been signed with
+	if keySet.signing.id != "" {
+		jwtToken.Header["kid"] = keySet.signing.id
+	}
 
-	token, err := jwtToken.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	token, err := jwtToken.SignedString(keySet.signing.signingKey)
 	if err != nil {
 		return "", err
 	}
@@ -67,15 +146,12 @@ func GenerateToken(user *models.User) (string, error) {
 }
 
 func VerifyToken(token string) (*JwtPayload, error) {
-	keyFunc := func(token *jwt.Token) (interface{}, error) {
-		_, ok := token.Method.(*jwt.SigningMethodHMAC)
-		if !ok {
-			return nil, ErrInvalidToken
-		}
-		return []byte(os.Getenv("JWT_SECRET")), nil
+	keySet, err := getJwtKeys()
+	if err != nil {
+		return nil, err
 	}
 
-	jwtToken, err := jwt.ParseWithClaims(token, &JwtPayload{}, keyFunc)
+	jwtToken, err := jwt.ParseWithClaims(token, &JwtPayload{}, keySet.keyFunc)
 	if err != nil {
 		valErr, ok := err.(*jwt.ValidationError)
 		if ok && errors.Is(valErr.Inner, ErrExpiredToken) {
@@ -101,9 +177,23 @@ func CheckUserId(c *gin.Context, userId xid.ID) *JwtPayload {
 	return authPayload
 }
 
-func CheckUserRole(c *gin.Context) *JwtPayload {
+func (p *JwtPayload) HasPermission(permission string) bool {
+	for _, name := range p.Permissions {
+		if name == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// most of the permission checks are done by the RequirePermission middleware, this
+// is for the handlers that let in either the owner of a resource or whoever has
+// the permission... like the middleware, the permissions only count when the
+// user has signed in with a second factor
+func CheckPermission(c *gin.Context, permission string) *JwtPayload {
 	authPayload := c.MustGet(JwtPayloadKey).(*JwtPayload)
-	if authPayload.Role != RoleAdmin {
+	if !authPayload.Mfa || !authPayload.HasPermission(permission) {
 		return nil
 	}
 
@@ -0,0 +1,227 @@
+package libs
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	ErrNoJwtKeys          = errors.New("either JWT_SECRET or JWT_KEYS_DIR must be set")
+	ErrSigningKeyNotFound = errors.New("the JWT_SIGNING_KEY_ID key is not a private key in JWT_KEYS_DIR")
+)
+
+// a key that tokens are signed and/or verified with, the keys loaded from public
+// key files can only be used to verify tokens (e.g. the previous key during a rotation)
+type jwtKey struct {
+	id              string
+	method          jwt.SigningMethod
+	signingKey      interface{}
+	verificationKey interface{}
+}
+
+type jwtKeySet struct {
+	signing *jwtKey
+	// the asymmetric keys by their kid
+	keys map[string]*jwtKey
+	// the legacy HS256 secret, it verifies the tokens that have no kid
+	secret *jwtKey
+}
+
+var (
+	jwtKeys     *jwtKeySet
+	jwtKeysErr  error
+	jwtKeysOnce sync.Once
+)
+
+// loads the keys the tokens are signed and verified with, it is called once at
+// startup so a misconfiguration is noticed right away rather than on the first
+// sign in... the keys are looked up as follows:
+//
+// if JWT_KEYS_DIR is set every <kid>.pem file in it is loaded (RSA or Ed25519,
+// either private or public keys) and the tokens are signed with the private key
+// named by JWT_SIGNING_KEY_ID, to rotate the keys a new key is added and made the
+// signing one while the old one is kept around (its public key is enough) until
+// the tokens it has signed have expired
+//
+// if JWT_SECRET is set the tokens without a kid are verified with it (HS256),
+// and when there is no JWT_KEYS_DIR they are signed with it too
+func LoadJwtKeys() error {
+	_, err := getJwtKeys()
+	return err
+}
+
+func getJwtKeys() (*jwtKeySet, error) {
+	jwtKeysOnce.Do(func() {
+		jwtKeys, jwtKeysErr = loadJwtKeys(os.Getenv("JWT_KEYS_DIR"), os.Getenv("JWT_SIGNING_KEY_ID"), os.Getenv("JWT_SECRET"))
+	})
+	return jwtKeys, jwtKeysErr
+}
+
+func loadJwtKeys(dir, signingKeyId, secret string) (*jwtKeySet, error) {
+	keySet := &jwtKeySet{keys: make(map[string]*jwtKey)}
+
+	if secret != "" {
+		keySet.secret = &jwtKey{
+			method:          jwt.SigningMethodHS256,
+			signingKey:      []byte(secret),
+			verificationKey: []byte(secret),
+		}
+	}
+
+	if dir == "" {
+		if keySet.secret == nil {
+			return nil, ErrNoJwtKeys
+		}
+
+		keySet.signing = keySet.secret
+		return keySet, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := parseJwtKey(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keySet.keys[kid] = key
+	}
+
+	signing, ok := keySet.keys[signingKeyId]
+	if !ok || signing.signingKey == nil {
+		return nil, ErrSigningKeyNotFound
+	}
+	keySet.signing = signing
+
+	return keySet, nil
+}
+
+func parseJwtKey(kid string, data []byte) (*jwtKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var privateKey, publicKey interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		publicKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &jwtKey{id: kid, signingKey: privateKey}
+	switch k := privateKey.(type) {
+	case *rsa.PrivateKey:
+		publicKey = &k.PublicKey
+	case ed25519.PrivateKey:
+		publicKey = k.Public()
+	case nil:
+	default:
+		return nil, errors.New("only RSA and Ed25519 keys are supported")
+	}
+
+	switch publicKey.(type) {
+	case *rsa.PublicKey:
+		key.method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, errors.New("only RSA and Ed25519 keys are supported")
+	}
+	key.verificationKey = publicKey
+
+	return key, nil
+}
+
+// finds the key a token must be verified with, a token with a kid must be signed
+// by that exact key and with its algorithm (otherwise e.g. a token signed with
+// HS256 using the public key as the secret would be accepted)
+func (ks *jwtKeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key := ks.secret
+	if kid != "" {
+		key = ks.keys[kid]
+	}
+	if key == nil || token.Method.Alg() != key.method.Alg() {
+		return nil, ErrInvalidToken
+	}
+
+	return key.verificationKey, nil
+}
+
+// a JSON web key (RFC 7517), only the public parts of the keys are published
+type Jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// the public keys that tokens can be verified with, the HS256 secret (if any) is
+// of course never published
+func Jwks() ([]Jwk, error) {
+	keySet, err := getJwtKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	jwks := []Jwk{}
+	for _, key := range keySet.keys {
+		jwk := Jwk{Kid: key.id, Alg: key.method.Alg(), Use: "sig"}
+		switch k := key.verificationKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(k)
+		}
+		jwks = append(jwks, jwk)
+	}
+
+	sort.Slice(jwks, func(i, j int) bool {
+		return jwks[i].Kid < jwks[j].Kid
+	})
+
+	return jwks, nil
+}
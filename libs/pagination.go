@@ -0,0 +1,176 @@
+package libs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPerPage = 20
+	MaxPerPage     = 100
+)
+
+var (
+	ErrInvalidCursor      = errors.New("invalid cursor")
+	ErrInvalidPage        = errors.New("page must be a positive number")
+	ErrInvalidPageLimit   = errors.New("limit must be a positive number")
+	ErrPaginationConflict = errors.New("cursor and page cannot be used together")
+	ErrCursorNotSupported = errors.New("cursor cannot be used with a custom sort order, use page instead")
+)
+
+// every list endpoint is paginated in one of two ways:
+//
+//   - cursor based (the default): the client sends back the next_cursor of the
+//     previous response as the cursor param, this is possible because the
+//     primary keys are xids which are sortable by the time they are created
+//   - offset based: the client asks for a page number with the page param
+//
+// in both cases the size of a page can be set with either the limit or the
+// per_page param, the records are always sorted from the newest to the oldest
+type Pagination struct {
+	Limit      int    `json:"limit"`
+	Page       int    `json:"page,omitempty"`
+	Cursor     string `json:"cursor,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      int64  `json:"total"`
+
+	cursor xid.ID
+}
+
+func NewPagination(c *gin.Context) (*Pagination, error) {
+	p := &Pagination{Limit: DefaultPerPage}
+
+	limit := c.Query("limit")
+	if limit == "" {
+		limit = c.Query("per_page")
+	}
+	if limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return nil, ErrInvalidPageLimit
+		}
+		if n > MaxPerPage {
+			n = MaxPerPage
+		}
+		p.Limit = n
+	}
+
+	if page := c.Query("page"); page != "" {
+		n, err := strconv.Atoi(page)
+		if err != nil || n < 1 {
+			return nil, ErrInvalidPage
+		}
+		p.Page = n
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		if p.Page > 0 {
+			return nil, ErrPaginationConflict
+		}
+
+		id, err := xid.FromString(cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		p.Cursor = cursor
+		p.cursor = id
+	}
+
+	return p, nil
+}
+
+func (p *Pagination) IsCursorBased() bool {
+	return p.Page == 0
+}
+
+// the cursor only works when the records are sorted by their ids, so lists that
+// are sorted some other way must fall back to the offset based pagination
+func (p *Pagination) UseOffset() error {
+	if p.Cursor != "" {
+		return ErrCursorNotSupported
+	}
+
+	if p.Page == 0 {
+		p.Page = 1
+	}
+
+	return nil
+}
+
+// a gorm scope that selects the requested page of records, the column must be
+// the (xid) primary key of the paginated table
+func (p *Pagination) Paginate(column string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Order(column + " DESC").Limit(p.Limit)
+
+		if !p.IsCursorBased() {
+			return db.Offset((p.Page - 1) * p.Limit)
+		}
+
+		if !p.cursor.IsNil() {
+			db = db.Where(column+" < ?", p.cursor)
+		}
+
+		return db
+	}
+}
+
+// must be called with the number of records found and the id of the last one,
+// if the page is full there might be more records after it
+func (p *Pagination) SetNextCursor(count int, lastId xid.ID) {
+	if p.IsCursorBased() && count == p.Limit {
+		p.NextCursor = lastId.String()
+	}
+}
+
+// sets the Link header (RFC 8288) with the urls of the other pages, the urls
+// keep every other query param of the current request (e.g. the search keyword)
+func (p *Pagination) SetLinkHeader(c *gin.Context) {
+	var links []string
+
+	link := func(rel string, set map[string]string) {
+		url := *c.Request.URL
+		query := url.Query()
+		query.Del("cursor")
+		query.Del("page")
+		for key, value := range set {
+			query.Set(key, value)
+		}
+		url.RawQuery = query.Encode()
+		links = append(links, fmt.Sprintf("<%s>; rel=\"%s\"", url.RequestURI(), rel))
+	}
+
+	if p.IsCursorBased() {
+		link("first", nil)
+		if p.NextCursor != "" {
+			link("next", map[string]string{"cursor": p.NextCursor})
+		}
+	} else {
+		lastPage := int((p.Total + int64(p.Limit) - 1) / int64(p.Limit))
+		if lastPage < 1 {
+			lastPage = 1
+		}
+
+		link("first", map[string]string{"page": "1"})
+		if p.Page > 1 {
+			prevPage := p.Page - 1
+			if prevPage > lastPage {
+				prevPage = lastPage
+			}
+			link("prev", map[string]string{"page": strconv.Itoa(prevPage)})
+		}
+		if p.Page < lastPage {
+			link("next", map[string]string{"page": strconv.Itoa(p.Page + 1)})
+		}
+		link("last", map[string]string{"page": strconv.Itoa(lastPage)})
+	}
+
+	c.Header("Link", strings.Join(links, ", "))
+	c.Header("X-Total-Count", strconv.FormatInt(p.Total, 10))
+}
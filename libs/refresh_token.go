@@ -0,0 +1,48 @@
+package libs
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"time"
+)
+
+const defaultRefreshTokenTtl = 30 * 24 * time.Hour
+
+// refresh tokens are opaque random strings, only their hashes are stored so a
+// leaked sessions table can't be used to sign in... unlike passwords they have
+// plenty of entropy so a fast hash (sha256) is enough
+func GenerateRefreshToken() (token string, hash string, err error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", "", err
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(bytes)
+	return token, HashRefreshToken(token), nil
+}
+
+func HashRefreshToken(token string) string {
+	return hashToken(token)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// the refresh token ttl can be configured with the REFRESH_TOKEN_TTL env var (e.g. "720h")
+func RefreshTokenTtl() time.Duration {
+	return durationFromEnv("REFRESH_TOKEN_TTL", defaultRefreshTokenTtl)
+}
+
+func durationFromEnv(key string, defaultValue time.Duration) time.Duration {
+	duration, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || duration <= 0 {
+		return defaultValue
+	}
+
+	return duration
+}
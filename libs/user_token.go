@@ -0,0 +1,83 @@
+package libs
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidUserToken  = errors.New("invalid or expired token")
+	ErrNoUserTokenSecret = errors.New("either USER_TOKEN_SECRET or JWT_SECRET must be set")
+)
+
+const (
+	EmailVerificationTtl = 24 * time.Hour
+	PasswordResetTtl     = time.Hour
+)
+
+const (
+	userTokenSeparator    = "."
+	userTokenRandomLength = 32
+)
+
+var userTokenEncoding = base64.RawURLEncoding
+
+// the tokens sent to the users (e.g. to verify their email addresses) look like
+// "<random>.<signature>" where the signature is an HMAC of the random part and the
+// purpose of the token, so a token can only be used for what it was issued for and
+// the forged ones are rejected without even hitting the database... the tokens
+// are single-use, which is taken care of by storing their hashes
+func GenerateUserToken(purpose string) (token string, hash string, err error) {
+	random := make([]byte, userTokenRandomLength)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", err
+	}
+
+	encoded := userTokenEncoding.EncodeToString(random)
+	signature, err := signUserToken(purpose, encoded)
+	if err != nil {
+		return "", "", err
+	}
+
+	token = encoded + userTokenSeparator + signature
+	return token, hashToken(token), nil
+}
+
+// checks the signature of the token and returns the hash it is stored with
+func VerifyUserToken(purpose, token string) (string, error) {
+	encoded, signature, ok := strings.Cut(token, userTokenSeparator)
+	if !ok {
+		return "", ErrInvalidUserToken
+	}
+
+	expected, err := signUserToken(purpose, encoded)
+	if err != nil {
+		return "", err
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return "", ErrInvalidUserToken
+	}
+
+	return hashToken(token), nil
+}
+
+func signUserToken(purpose, encoded string) (string, error) {
+	secret := os.Getenv("USER_TOKEN_SECRET")
+	if secret == "" {
+		secret = os.Getenv("JWT_SECRET")
+	}
+	if secret == "" {
+		return "", ErrNoUserTokenSecret
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose + userTokenSeparator + encoded))
+	return userTokenEncoding.EncodeToString(mac.Sum(nil)), nil
+}
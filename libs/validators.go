@@ -0,0 +1,93 @@
+package libs
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// the usernames are stored in a varchar(24) column, see models.User
+var usernameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,24}$`)
+
+// an optional leading plus and 7 to 15 digits (the E.164 limit), the digits can
+// be grouped with single spaces or dashes
+var phoneNumberRegex = regexp.MustCompile(`^\+?[0-9](?:[ -]?[0-9]){6,14}$`)
+
+// the zip codes of the countries we know about, the others only have to look
+// like a zip code at all
+var zipCodeRegexes = map[string]*regexp.Regexp{
+	"AU": regexp.MustCompile(`^[0-9]{4}$`),
+	"CA": regexp.MustCompile(`^[A-Z][0-9][A-Z] ?[0-9][A-Z][0-9]$`),
+	"DE": regexp.MustCompile(`^[0-9]{5}$`),
+	"FR": regexp.MustCompile(`^[0-9]{5}$`),
+	"GB": regexp.MustCompile(`^[A-Z]{1,2}[0-9][A-Z0-9]? ?[0-9][A-Z]{2}$`),
+	"ID": regexp.MustCompile(`^[0-9]{5}$`),
+	"IN": regexp.MustCompile(`^[0-9]{6}$`),
+	"JP": regexp.MustCompile(`^[0-9]{3}-?[0-9]{4}$`),
+	"MY": regexp.MustCompile(`^[0-9]{5}$`),
+	"NL": regexp.MustCompile(`^[0-9]{4} ?[A-Z]{2}$`),
+	"SG": regexp.MustCompile(`^[0-9]{6}$`),
+	"US": regexp.MustCompile(`^[0-9]{5}(?:-[0-9]{4})?$`),
+}
+
+var defaultZipCodeRegex = regexp.MustCompile(`^[A-Z0-9][A-Z0-9 -]{1,8}[A-Z0-9]$`)
+
+// adds our own rules to the validator gin binds the requests with, and makes
+// the validation errors use the json names of the fields so the clients can
+// tell which of their fields are wrong
+func RegisterValidators() error {
+	validate, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("unexpected validator engine")
+	}
+
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	if err := validate.RegisterValidation("username", isUsername); err != nil {
+		return err
+	}
+	if err := validate.RegisterValidation("phone", isPhoneNumber); err != nil {
+		return err
+	}
+
+	return validate.RegisterValidation("zip_code", isZipCode)
+}
+
+func isUsername(fl validator.FieldLevel) bool {
+	return usernameRegex.MatchString(fl.Field().String())
+}
+
+func isPhoneNumber(fl validator.FieldLevel) bool {
+	return phoneNumberRegex.MatchString(fl.Field().String())
+}
+
+// the param is the name of the field that holds the country code, e.g.
+// `binding:"zip_code=Country"`
+func isZipCode(fl validator.FieldLevel) bool {
+	zipCode := strings.ToUpper(fl.Field().String())
+
+	country, kind, _, found := fl.GetStructFieldOKAdvanced2(fl.Parent(), fl.Param())
+	if !found || kind != reflect.String {
+		return defaultZipCodeRegex.MatchString(zipCode)
+	}
+
+	regex, ok := zipCodeRegexes[strings.ToUpper(country.String())]
+	if !ok {
+		regex = defaultZipCodeRegex
+	}
+
+	return regex.MatchString(zipCode)
+}
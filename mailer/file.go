@@ -0,0 +1,40 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+const defaultMailerDir = "mails"
+
+// writes every email to its own .eml file so they can be opened with any email
+// client, the file names start with the time the emails were sent at
+type fileMailer struct {
+	dir string
+}
+
+func NewFileMailer(dir string) (Mailer, error) {
+	if dir == "" {
+		dir = defaultMailerDir
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &fileMailer{dir}, nil
+}
+
+func (fm *fileMailer) Send(to, subject, body string) error {
+	now := time.Now()
+	name := fmt.Sprintf("%s-%s.eml", now.Format("20060102T150405"), xid.New())
+
+	content := fmt.Sprintf("To: %s\r\nSubject: %s\r\nDate: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		to, subject, now.Format(time.RFC1123Z), body)
+
+	return os.WriteFile(filepath.Join(fm.dir, name), []byte(content), 0644)
+}
@@ -0,0 +1,21 @@
+package mailer
+
+import (
+	"log"
+	"os"
+)
+
+type logMailer struct {
+	logger *log.Logger
+}
+
+func NewLogMailer() Mailer {
+	return &logMailer{
+		log.New(os.Stdout, "[mailer] ", log.LstdFlags),
+	}
+}
+
+func (lm *logMailer) Send(to, subject, body string) error {
+	lm.logger.Printf("to=%s subject=%q body=%q\n", to, subject, body)
+	return nil
+}
@@ -0,0 +1,32 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	LogMailerName  = "log"
+	FileMailerName = "file"
+)
+
+// a mailer sends emails to users, there are only implementations meant for local
+// development for now (the emails are logged or written to files), a real one
+// (smtp, some email api, etc.) only needs to satisfy this interface and be
+// selected in NewMailer
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// the mailer is selected with the MAILER env var, the log mailer is used when it
+// is empty... the file mailer writes the emails to MAILER_DIR
+func NewMailer(name string) (Mailer, error) {
+	switch name {
+	case "", LogMailerName:
+		return NewLogMailer(), nil
+	case FileMailerName:
+		return NewFileMailer(os.Getenv("MAILER_DIR"))
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", name)
+	}
+}
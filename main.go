@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"github.com/joho/godotenv"
 	"github.com/laluardian/gin-ecommerce-api/routes"
@@ -14,5 +15,12 @@ func main() {
 		log.Fatal("Error loading .env file")
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	log.Fatal(routes.RunApi())
 }
@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laluardian/gin-ecommerce-api/apperrors"
+)
+
+const problemContentType = "application/problem+json"
+
+// renders the last error the handlers (or the other middlewares) have added with
+// c.Error as problem details (RFC 7807), it must be the first middleware so it
+// sees the errors of all the others... the errors of the requests that already
+// have a response are only logged
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		ginErr := c.Errors.Last()
+		if ginErr == nil {
+			return
+		}
+
+		appErr := apperrors.From(ginErr.Err)
+		if appErr.Status >= http.StatusInternalServerError || c.Writer.Written() {
+			log.Println("Error handling", c.Request.Method, c.Request.URL.Path+":", ginErr.Err)
+		}
+
+		if c.Writer.Written() {
+			return
+		}
+
+		problem := gin.H{
+			"type":     "about:blank",
+			"title":    http.StatusText(appErr.Status),
+			"status":   appErr.Status,
+			"detail":   appErr.Message,
+			"instance": c.Request.URL.Path,
+			"code":     appErr.Code,
+		}
+		if len(appErr.Fields) > 0 {
+			problem["errors"] = appErr.Fields
+		}
+		for key, value := range appErr.Extensions {
+			problem[key] = value
+		}
+
+		c.Header("Content-Type", problemContentType)
+		c.JSON(appErr.Status, problem)
+	}
+}
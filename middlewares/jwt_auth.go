@@ -1,35 +1,93 @@
 package middlewares
 
 import (
-	"net/http"
+	"encoding/json"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/laluardian/gin-ecommerce-api/apperrors"
 	"github.com/laluardian/gin-ecommerce-api/libs"
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/laluardian/gin-ecommerce-api/repositories"
+	"github.com/laluardian/gin-ecommerce-api/revocation"
 )
 
-func JwtAuthorization() gin.HandlerFunc {
+// every request made with an impersonation token is audited before it is handled,
+// a request that can't be audited isn't handled at all
+func JwtAuthorization(revocations revocation.RevocationStore, auditLogs repositories.AuditLogRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if len(authHeader) == 0 {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "Authorization header not found",
-			})
+			c.Error(apperrors.Unauthorized("Authorization header not found"))
+			c.Abort()
+			return
 		}
 
 		// the Authorization header value looks more or less like this: "Bearer TheToken"
 		// in this case we want to get only the "TheToken" part
 		const bearerSchema = "Bearer "
+		if !strings.HasPrefix(authHeader, bearerSchema) {
+			c.Error(apperrors.Unauthorized("Authorization header must use the Bearer scheme"))
+			c.Abort()
+			return
+		}
 		getToken := authHeader[len(bearerSchema):]
 
 		payload, err := libs.VerifyToken(getToken)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": err.Error(),
-			})
+			c.Error(apperrors.Unauthorized(err.Error()))
+			c.Abort()
 			return
 		}
 
+		// the scoped tokens (e.g. the mfa pending ones) are only accepted by the
+		// endpoints they are meant for
+		if payload.Scope != "" {
+			c.Error(apperrors.Unauthorized(libs.ErrInvalidToken.Error()))
+			c.Abort()
+			return
+		}
+
+		// a token that is valid on its own might still have been revoked (e.g. the
+		// user has changed the password or has been signed out everywhere)
+		revoked, err := revocations.IsRevoked(payload)
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.Error(apperrors.Unauthorized("token is revoked"))
+			c.Abort()
+			return
+		}
+
+		if payload.IsImpersonated() {
+			if err := auditImpersonatedRequest(c, auditLogs, payload); err != nil {
+				c.Error(err)
+				c.Abort()
+				return
+			}
+		}
+
 		c.Set(libs.JwtPayloadKey, payload)
 		c.Next()
 	}
 }
+
+func auditImpersonatedRequest(c *gin.Context, auditLogs repositories.AuditLogRepository, payload *libs.JwtPayload) error {
+	details, _ := json.Marshal(gin.H{
+		"method": c.Request.Method,
+		"path":   c.Request.URL.Path,
+		"jti":    payload.Jti,
+	})
+
+	return auditLogs.Create(&models.AuditLog{
+		ActorID:    payload.Act.Sub,
+		Action:     models.AuditActionImpersonatedRequest,
+		TargetType: "user",
+		TargetID:   payload.Sub,
+		Details:    string(details),
+		IPAddress:  c.ClientIP(),
+	})
+}
@@ -0,0 +1,30 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/laluardian/gin-ecommerce-api/apperrors"
+	"github.com/laluardian/gin-ecommerce-api/libs"
+)
+
+// only lets the users that have the permission through, it must come after
+// JwtAuthorization since it relies on the jwt payload... two-factor authentication
+// is mandatory for everyone that has permissions so the token must have been
+// issued after the second factor was checked
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		payload := c.MustGet(libs.JwtPayloadKey).(*libs.JwtPayload)
+		if !payload.HasPermission(permission) {
+			c.Error(apperrors.Forbidden("Missing permission " + permission))
+			c.Abort()
+			return
+		}
+
+		if !payload.Mfa {
+			c.Error(apperrors.Forbidden("Two-factor authentication is required, please enable it and sign in again"))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,22 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/laluardian/gin-ecommerce-api/apperrors"
+	"github.com/laluardian/gin-ecommerce-api/libs"
+)
+
+// only lets the users whose email addresses are verified through, it must come
+// after JwtAuthorization since it relies on the jwt payload
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		payload := c.MustGet(libs.JwtPayloadKey).(*libs.JwtPayload)
+		if !payload.EmailVerified {
+			c.Error(apperrors.Forbidden("Email address is not verified"))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/laluardian/gin-ecommerce-api/libs"
+	"github.com/laluardian/gin-ecommerce-api/migrations"
+)
+
+const migrateUsage = `usage: go run . migrate <command>
+
+commands:
+  up           apply every pending migration
+  down N       undo the last N applied migrations
+  status       list the migrations and whether they are applied
+  create NAME  create a new (empty) migration in ` + migrations.Dir
+
+// the migrate command, e.g. go run . migrate up... create only touches the files
+// in the repo while the other commands connect to the DATA_SOURCE_NAME database
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch command := args[0]; {
+	case command == "create" && len(args) == 2:
+		upPath, downPath, err := migrations.Create(migrations.Dir, args[1])
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Created", upPath)
+		fmt.Println("Created", downPath)
+		return nil
+
+	case command == "up" && len(args) == 1:
+		migrator, err := newMigrator()
+		if err != nil {
+			return err
+		}
+
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			fmt.Printf("Applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("The database schema is already up to date")
+		}
+		return err
+
+	case command == "down" && len(args) == 2:
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return errors.New("N must be a positive number")
+		}
+
+		migrator, err := newMigrator()
+		if err != nil {
+			return err
+		}
+
+		reverted, err := migrator.Down(n)
+		for _, migration := range reverted {
+			fmt.Printf("Reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+		return err
+
+	case command == "status" && len(args) == 1:
+		migrator, err := newMigrator()
+		if err != nil {
+			return err
+		}
+
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+
+		return printMigrationStatuses(statuses)
+	}
+
+	return errors.New(migrateUsage)
+}
+
+func newMigrator() (migrations.Migrator, error) {
+	return migrations.NewMigrator(libs.OpenDB(os.Getenv("DATA_SOURCE_NAME")))
+}
+
+func printMigrationStatuses(statuses []migrations.MigrationStatus) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS")
+
+	for _, status := range statuses {
+		state := "pending"
+		if !status.Pending() {
+			state = "applied at " + status.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		if status.Modified {
+			state += " (changed since it was applied)"
+		}
+		if status.Missing {
+			state += " (not in this binary)"
+		}
+
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, state)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,143 @@
+package migrations
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// the migrations are embedded in the binary so it can migrate the database it
+// is deployed with, they are only read from the disk when a new one is created
+//
+//go:embed sql/*.sql
+var files embed.FS
+
+// where the new migrations are created, relative to the root of the repo
+const Dir = "migrations/sql"
+
+// every migration is a pair of files, e.g. 0001_initial_schema.up.sql and
+// 0001_initial_schema.down.sql, the version is the number in front
+var fileNameRegex = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+var migrationNameRegex = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+var ErrInvalidName = errors.New("the name of a migration can only have lower case letters, numbers and underscores")
+
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// the checksum is taken from the up migration, changing a migration that has
+// already been applied is an error since the databases that have it won't see
+// the change
+func checksum(sql string) string {
+	sum := sha256.Sum256([]byte(sql))
+	return hex.EncodeToString(sum[:])
+}
+
+// loads the migrations in the order of their versions, each version must have an
+// up migration while the down migration is optional (but then it can't be undone)
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := fileNameRegex.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names, %s and %s", version, migration.Name, match[2])
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+			migration.Checksum = checksum(migration.Up)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	var migrations []Migration
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up migration", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+func embeddedMigrations() ([]Migration, error) {
+	sqlFiles, err := fs.Sub(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	return loadMigrations(sqlFiles)
+}
+
+// creates the files of a new migration in the dir with the next version, the
+// binary has to be built again for it to be applied
+func Create(dir, name string) (upPath, downPath string, err error) {
+	if !migrationNameRegex.MatchString(name) {
+		return "", "", ErrInvalidName
+	}
+
+	migrations, err := loadMigrations(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+
+	var version int64 = 1
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("%04d_%s", version, name))
+	upPath, downPath = base+".up.sql", base+".down.sql"
+
+	if err := os.WriteFile(upPath, []byte("-- "+name+"\n"), 0644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(downPath, []byte("-- undoes "+name+"\n"), 0644); err != nil {
+		return "", "", err
+	}
+
+	return upPath, downPath, nil
+}
@@ -0,0 +1,277 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSchemaBehind     = errors.New("the database schema is behind, there are pending migrations")
+	ErrChecksumMismatch = errors.New("an applied migration has been changed")
+	ErrNoDownMigration  = errors.New("the migration can't be undone")
+)
+
+// the key of the advisory lock that is held while migrating, so when more than
+// one replica is migrating at the same time the others just wait for the first
+// one and then find nothing left to apply
+const lockKey int64 = 7265636901
+
+// a record of every applied migration, the checksum is the one the migration
+// had when it was applied
+type appliedMigration struct {
+	Version   int64 `gorm:"primarykey;autoIncrement:false"`
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+func (appliedMigration) TableName() string {
+	return "schema_migrations"
+}
+
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+	// the migration has been applied but it isn't embedded in this binary (it
+	// has been applied by a newer version of the app)
+	Missing bool
+	// the migration has been changed since it was applied
+	Modified bool
+}
+
+func (ms *MigrationStatus) Pending() bool {
+	return ms.AppliedAt == nil
+}
+
+type Migrator interface {
+	Up() ([]Migration, error)
+	Down(n int) ([]Migration, error)
+	Status() ([]MigrationStatus, error)
+	Check() error
+}
+
+type migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *gorm.DB) (Migrator, error) {
+	migrations, err := embeddedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	return &migrator{db, migrations}, nil
+}
+
+// applies every pending migration in the order of their versions, each one in
+// its own transaction so a failed migration leaves the ones before it applied
+func (m *migrator) Up() (applied []Migration, err error) {
+	err = m.withLock(func(conn *gorm.DB) error {
+		records, err := findApplied(conn)
+		if err != nil {
+			return err
+		}
+
+		// nothing is applied on top of a migration that has been changed, the
+		// change would never make it into this database
+		for _, migration := range m.migrations {
+			if record, ok := records[migration.Version]; ok && record.Checksum != migration.Checksum {
+				return fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, migration.Version, migration.Name)
+			}
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := records[migration.Version]; ok {
+				continue
+			}
+
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := execSql(tx, migration.Up); err != nil {
+					return err
+				}
+
+				return tx.Create(&appliedMigration{
+					Version:   migration.Version,
+					Name:      migration.Name,
+					Checksum:  migration.Checksum,
+					AppliedAt: time.Now(),
+				}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			applied = append(applied, migration)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// undoes the last n applied migrations, the latest one first
+func (m *migrator) Down(n int) (reverted []Migration, err error) {
+	if n < 1 {
+		return nil, errors.New("the number of migrations to undo must be at least 1")
+	}
+
+	byVersion := make(map[int64]Migration)
+	for _, migration := range m.migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	err = m.withLock(func(conn *gorm.DB) error {
+		var records []appliedMigration
+		if err := conn.Order("version DESC").Limit(n).Find(&records).Error; err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			migration, ok := byVersion[record.Version]
+			if !ok {
+				return fmt.Errorf("migration %d_%s isn't embedded in this binary", record.Version, record.Name)
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("%w: %d_%s has no down migration", ErrNoDownMigration, migration.Version, migration.Name)
+			}
+
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := execSql(tx, migration.Down); err != nil {
+					return err
+				}
+
+				return tx.Delete(&appliedMigration{}, record.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			reverted = append(reverted, migration)
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// every embedded migration along with every applied one that isn't embedded,
+// in the order of their versions
+func (m *migrator) Status() ([]MigrationStatus, error) {
+	records, err := findApplied(m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if record, ok := records[migration.Version]; ok {
+			appliedAt := record.AppliedAt
+			status.AppliedAt = &appliedAt
+			status.Modified = record.Checksum != migration.Checksum
+			delete(records, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+
+	for _, record := range records {
+		appliedAt := record.AppliedAt
+		statuses = append(statuses, MigrationStatus{
+			Version:   record.Version,
+			Name:      record.Name,
+			AppliedAt: &appliedAt,
+			Missing:   true,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+
+	return statuses, nil
+}
+
+// returns an error when the schema isn't the one this binary expects... a schema
+// that is ahead (the missing migrations) is fine since the replicas running the
+// previous version keep serving while a new version is rolled out
+func (m *migrator) Check() error {
+	statuses, err := m.Status()
+	if err != nil {
+		return err
+	}
+
+	var pending int
+	for _, status := range statuses {
+		if status.Modified {
+			return fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, status.Version, status.Name)
+		}
+		if status.Pending() {
+			pending++
+		}
+	}
+
+	if pending > 0 {
+		return fmt.Errorf("%w (%d pending)", ErrSchemaBehind, pending)
+	}
+
+	return nil
+}
+
+// the advisory lock belongs to the session, so everything that is done while
+// holding it must run on the same connection
+func (m *migrator) withLock(fn func(conn *gorm.DB) error) error {
+	return m.db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", lockKey).Error; err != nil {
+			return err
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?)", lockKey)
+
+		err := conn.Exec(`CREATE TABLE IF NOT EXISTS "schema_migrations" (
+			"version" bigint NOT NULL,
+			"name" text NOT NULL,
+			"checksum" text NOT NULL,
+			"applied_at" timestamptz NOT NULL,
+			PRIMARY KEY ("version")
+		)`).Error
+		if err != nil {
+			return err
+		}
+
+		return fn(conn)
+	})
+}
+
+// the schema_migrations table is only created when migrating, until then every
+// migration is pending
+func findApplied(db *gorm.DB) (map[int64]appliedMigration, error) {
+	records := make(map[int64]appliedMigration)
+	if !db.Migrator().HasTable(&appliedMigration{}) {
+		return records, nil
+	}
+
+	var applied []appliedMigration
+	if err := db.Find(&applied).Error; err != nil {
+		return nil, err
+	}
+
+	for _, record := range applied {
+		records[record.Version] = record
+	}
+
+	return records, nil
+}
+
+// the migrations are executed as they are, without any arguments, so they can
+// have more than one statement and gorm doesn't try to replace anything in them
+func execSql(tx *gorm.DB, sql string) error {
+	_, err := tx.Statement.ConnPool.ExecContext(tx.Statement.Context, sql)
+	return err
+}
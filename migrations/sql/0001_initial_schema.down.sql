@@ -0,0 +1,13 @@
+-- drops every table in the reverse order they were created in
+
+DROP TABLE IF EXISTS "addresses";
+
+DROP TABLE IF EXISTS "product_categories";
+
+DROP TABLE IF EXISTS "categories";
+
+DROP TABLE IF EXISTS "user_wishlist_products";
+
+DROP TABLE IF EXISTS "products";
+
+DROP TABLE IF EXISTS "users";
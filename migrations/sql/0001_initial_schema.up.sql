@@ -0,0 +1,72 @@
+-- the schema as it was before the migrations, everything is only created if it
+-- doesn't exist yet so the databases that were automigrated by an older version
+-- of the app can be migrated (and then tracked) as well... every migration after
+-- this one is written the same way for the same reason
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" bytea NOT NULL,
+    "username" varchar(24) NOT NULL UNIQUE,
+    "email" text NOT NULL UNIQUE,
+    "password" text NOT NULL,
+    "is_admin" boolean NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "products" (
+    "id" bytea NOT NULL UNIQUE,
+    "name" text NOT NULL,
+    "description" text NOT NULL,
+    "price" bigint NOT NULL,
+    "discount" smallint,
+    "quantity" bigint NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE INDEX IF NOT EXISTS "idx_products_name" ON "products" ("name");
+
+CREATE TABLE IF NOT EXISTS "user_wishlist_products" (
+    "product_id" bytea NOT NULL,
+    "user_id" bytea NOT NULL,
+    PRIMARY KEY ("product_id","user_id"),
+    CONSTRAINT "fk_user_wishlist_products_product" FOREIGN KEY ("product_id") REFERENCES "products"("id"),
+    CONSTRAINT "fk_user_wishlist_products_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+
+CREATE TABLE IF NOT EXISTS "categories" (
+    "id" bytea NOT NULL UNIQUE,
+    "name" text NOT NULL UNIQUE,
+    "description" text,
+    "slug" text NOT NULL UNIQUE,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "product_categories" (
+    "category_id" bytea NOT NULL,
+    "product_id" bytea NOT NULL,
+    PRIMARY KEY ("category_id","product_id"),
+    CONSTRAINT "fk_product_categories_category" FOREIGN KEY ("category_id") REFERENCES "categories"("id"),
+    CONSTRAINT "fk_product_categories_product" FOREIGN KEY ("product_id") REFERENCES "products"("id")
+);
+
+CREATE TABLE IF NOT EXISTS "addresses" (
+    "id" bytea NOT NULL,
+    "address_name" varchar(32) NOT NULL,
+    "receiver_name" varchar(32) NOT NULL,
+    "receiver_phone_number" text NOT NULL,
+    "street_address" varchar(64) NOT NULL,
+    "city" text NOT NULL,
+    "province" text NOT NULL,
+    "country" text NOT NULL,
+    "zip_code" text NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "user_id" bytea NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_users_addresses" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
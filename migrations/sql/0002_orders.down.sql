@@ -0,0 +1,5 @@
+-- drops the orders
+
+DROP TABLE IF EXISTS "order_items";
+
+DROP TABLE IF EXISTS "orders";
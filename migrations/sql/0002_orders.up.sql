@@ -0,0 +1,34 @@
+-- the orders along with the price snapshot of every ordered product
+
+CREATE TABLE IF NOT EXISTS "orders" (
+    "id" bytea NOT NULL,
+    "status" varchar(16) NOT NULL,
+    "total_price" bigint NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "user_id" bytea NOT NULL,
+    "address_id" bytea,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_orders_address" FOREIGN KEY ("address_id") REFERENCES "addresses"("id") ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "idx_orders_status" ON "orders" ("status");
+
+CREATE INDEX IF NOT EXISTS "idx_orders_user_id" ON "orders" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "order_items" (
+    "id" bytea NOT NULL,
+    "name" text NOT NULL,
+    "quantity" bigint NOT NULL,
+    "price" bigint NOT NULL,
+    "discount" smallint,
+    "subtotal" bigint NOT NULL,
+    "created_at" timestamptz,
+    "order_id" bytea NOT NULL,
+    "product_id" bytea,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_order_items_product" FOREIGN KEY ("product_id") REFERENCES "products"("id") ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT "fk_orders_items" FOREIGN KEY ("order_id") REFERENCES "orders"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "idx_order_items_order_id" ON "order_items" ("order_id");
@@ -0,0 +1,5 @@
+-- drops the stock reservations
+
+DROP TABLE IF EXISTS "reservation_items";
+
+DROP TABLE IF EXISTS "reservations";
@@ -0,0 +1,28 @@
+-- the stock reservations, they are released by the sweeper once they expire
+
+CREATE TABLE IF NOT EXISTS "reservations" (
+    "id" bytea NOT NULL,
+    "status" varchar(16) NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "user_id" bytea NOT NULL,
+    PRIMARY KEY ("id")
+);
+
+CREATE INDEX IF NOT EXISTS "idx_reservations_expires_at" ON "reservations" ("expires_at");
+
+CREATE INDEX IF NOT EXISTS "idx_reservations_status" ON "reservations" ("status");
+
+CREATE INDEX IF NOT EXISTS "idx_reservations_user_id" ON "reservations" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "reservation_items" (
+    "id" bytea NOT NULL,
+    "quantity" bigint NOT NULL,
+    "reservation_id" bytea NOT NULL,
+    "product_id" bytea NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_reservations_items" FOREIGN KEY ("reservation_id") REFERENCES "reservations"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "idx_reservation_items_reservation_id" ON "reservation_items" ("reservation_id");
@@ -0,0 +1,5 @@
+-- drops the shopping carts
+
+DROP TABLE IF EXISTS "cart_items";
+
+DROP TABLE IF EXISTS "carts";
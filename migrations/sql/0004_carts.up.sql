@@ -0,0 +1,25 @@
+-- the persistent shopping carts, one per user
+
+CREATE TABLE IF NOT EXISTS "carts" (
+    "id" bytea NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "user_id" bytea NOT NULL,
+    PRIMARY KEY ("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_carts_user_id" ON "carts" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "cart_items" (
+    "id" bytea NOT NULL,
+    "quantity" bigint NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "cart_id" bytea NOT NULL,
+    "product_id" bytea NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_cart_items_product" FOREIGN KEY ("product_id") REFERENCES "products"("id") ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT "fk_carts_items" FOREIGN KEY ("cart_id") REFERENCES "carts"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_cart_items_cart_product" ON "cart_items" ("cart_id","product_id");
@@ -0,0 +1,14 @@
+-- removes the snapshots, the wishlist items no longer go along with the users or
+-- the products
+
+ALTER TABLE "user_wishlist_products"
+    DROP CONSTRAINT IF EXISTS "fk_user_wishlist_products_product",
+    ADD CONSTRAINT "fk_user_wishlist_products_product" FOREIGN KEY ("product_id") REFERENCES "products"("id"),
+    DROP CONSTRAINT IF EXISTS "fk_user_wishlist_products_user",
+    ADD CONSTRAINT "fk_user_wishlist_products_user" FOREIGN KEY ("user_id") REFERENCES "users"("id");
+
+ALTER TABLE "user_wishlist_products"
+    DROP COLUMN IF EXISTS "created_at",
+    DROP COLUMN IF EXISTS "quantity",
+    DROP COLUMN IF EXISTS "discount",
+    DROP COLUMN IF EXISTS "price";
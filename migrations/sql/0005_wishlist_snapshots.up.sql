@@ -0,0 +1,14 @@
+-- the wishlist keeps a snapshot of the product as it was when it was wishlisted,
+-- and the wishlist items go along with the user or the product they belong to
+
+ALTER TABLE "user_wishlist_products"
+    ADD COLUMN IF NOT EXISTS "price" bigint NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS "discount" smallint NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS "quantity" bigint NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS "created_at" timestamptz;
+
+ALTER TABLE "user_wishlist_products"
+    DROP CONSTRAINT IF EXISTS "fk_user_wishlist_products_product",
+    ADD CONSTRAINT "fk_user_wishlist_products_product" FOREIGN KEY ("product_id") REFERENCES "products"("id") ON DELETE CASCADE ON UPDATE CASCADE,
+    DROP CONSTRAINT IF EXISTS "fk_user_wishlist_products_user",
+    ADD CONSTRAINT "fk_user_wishlist_products_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,3 @@
+-- drops the payments
+
+DROP TABLE IF EXISTS "payments";
@@ -0,0 +1,21 @@
+-- the payments of the orders
+
+CREATE TABLE IF NOT EXISTS "payments" (
+    "id" bytea NOT NULL,
+    "provider" varchar(32) NOT NULL,
+    "reference" text,
+    "amount" bigint NOT NULL,
+    "status" varchar(16) NOT NULL,
+    "failure_reason" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "order_id" bytea NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_payments_order" FOREIGN KEY ("order_id") REFERENCES "orders"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "idx_payments_order_id" ON "payments" ("order_id");
+
+CREATE INDEX IF NOT EXISTS "idx_payments_reference" ON "payments" ("reference");
+
+CREATE INDEX IF NOT EXISTS "idx_payments_status" ON "payments" ("status");
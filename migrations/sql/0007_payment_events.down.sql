@@ -0,0 +1,3 @@
+-- drops the payment events
+
+DROP TABLE IF EXISTS "payment_events";
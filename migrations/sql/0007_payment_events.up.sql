@@ -0,0 +1,24 @@
+-- the events received through the payment webhook, an event is only ever stored
+-- once per provider
+
+CREATE TABLE IF NOT EXISTS "payment_events" (
+    "id" bytea NOT NULL,
+    "provider" varchar(32) NOT NULL,
+    "event_id" text NOT NULL,
+    "type" text NOT NULL,
+    "reference" text,
+    "payload" text NOT NULL,
+    "signature" text NOT NULL,
+    "timestamp" timestamptz NOT NULL,
+    "status" varchar(16) NOT NULL,
+    "error" text,
+    "processed_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE INDEX IF NOT EXISTS "idx_payment_events_reference" ON "payment_events" ("reference");
+
+CREATE INDEX IF NOT EXISTS "idx_payment_events_status" ON "payment_events" ("status");
+
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_payment_events_provider_event" ON "payment_events" ("provider","event_id");
@@ -0,0 +1,3 @@
+-- drops the product reviews
+
+DROP TABLE IF EXISTS "reviews";
@@ -0,0 +1,20 @@
+-- the product reviews, a user can only review a product once
+
+CREATE TABLE IF NOT EXISTS "reviews" (
+    "id" bytea NOT NULL,
+    "rating" smallint NOT NULL,
+    "title" varchar(64),
+    "body" text,
+    "is_hidden" boolean NOT NULL DEFAULT false,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "user_id" bytea NOT NULL,
+    "product_id" bytea NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_reviews_product" FOREIGN KEY ("product_id") REFERENCES "products"("id") ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT "fk_reviews_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "idx_reviews_product_id" ON "reviews" ("product_id");
+
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_reviews_user_product" ON "reviews" ("user_id","product_id");
@@ -0,0 +1,5 @@
+-- drops the full-text search of the products
+
+DROP INDEX IF EXISTS "idx_products_search_vector";
+
+ALTER TABLE "products" DROP COLUMN IF EXISTS "search_vector";
@@ -0,0 +1,10 @@
+-- the full-text search of the products runs on a generated tsvector column (the
+-- name weighs more than the description) with a GIN index
+
+ALTER TABLE "products" ADD COLUMN IF NOT EXISTS "search_vector" tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce("name", '')), 'A') ||
+        setweight(to_tsvector('english', coalesce("description", '')), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS "idx_products_search_vector" ON "products" USING GIN ("search_vector");
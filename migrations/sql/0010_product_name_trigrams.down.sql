@@ -0,0 +1,3 @@
+-- the pg_trgm extension is left in place since other things might be using it
+
+DROP INDEX IF EXISTS "idx_products_name_trgm";
@@ -0,0 +1,5 @@
+-- the name suggestions need the pg_trgm extension and a trigram index
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS "idx_products_name_trgm" ON "products" USING GIN ("name" gin_trgm_ops);
@@ -0,0 +1,3 @@
+-- drops the sessions
+
+DROP TABLE IF EXISTS "sessions";
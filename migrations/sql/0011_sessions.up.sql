@@ -0,0 +1,22 @@
+-- the sessions behind the rotating refresh tokens
+
+CREATE TABLE IF NOT EXISTS "sessions" (
+    "id" bytea NOT NULL,
+    "family_id" bytea NOT NULL,
+    "token_hash" text NOT NULL,
+    "user_agent" text,
+    "ip_address" text,
+    "expires_at" timestamptz NOT NULL,
+    "rotated_at" timestamptz,
+    "revoked_at" timestamptz,
+    "created_at" timestamptz,
+    "user_id" bytea NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_sessions_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "idx_sessions_family_id" ON "sessions" ("family_id");
+
+CREATE INDEX IF NOT EXISTS "idx_sessions_user_id" ON "sessions" ("user_id");
+
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_sessions_token_hash" ON "sessions" ("token_hash");
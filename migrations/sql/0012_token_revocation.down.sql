@@ -0,0 +1,5 @@
+-- drops the token revocation
+
+DROP TABLE IF EXISTS "revoked_tokens";
+
+ALTER TABLE "users" DROP COLUMN IF EXISTS "token_generation";
@@ -0,0 +1,16 @@
+-- the revoked access tokens (until they expire anyway) and the generation of the
+-- tokens of every user, bumping it revokes every token issued before
+
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "token_generation" bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS "revoked_tokens" (
+    "jti" text,
+    "user_id" bytea NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("jti")
+);
+
+CREATE INDEX IF NOT EXISTS "idx_revoked_tokens_expires_at" ON "revoked_tokens" ("expires_at");
+
+CREATE INDEX IF NOT EXISTS "idx_revoked_tokens_user_id" ON "revoked_tokens" ("user_id");
@@ -0,0 +1,5 @@
+-- drops the email tokens and forgets which emails have been verified
+
+DROP TABLE IF EXISTS "user_tokens";
+
+ALTER TABLE "users" DROP COLUMN IF EXISTS "email_verified_at";
@@ -0,0 +1,21 @@
+-- brings the is_admin column back (the users with the admin role are the admins)
+-- and drops the roles and the permissions
+
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "is_admin" boolean NOT NULL DEFAULT false;
+
+UPDATE "users" SET "is_admin" = true
+WHERE "id" IN (
+    SELECT ur."user_id" FROM "user_roles" ur
+    JOIN "roles" r ON r."id" = ur."role_id"
+    WHERE r."name" = 'admin'
+);
+
+ALTER TABLE "users" ALTER COLUMN "is_admin" DROP DEFAULT;
+
+DROP TABLE IF EXISTS "user_roles";
+
+DROP TABLE IF EXISTS "role_permissions";
+
+DROP TABLE IF EXISTS "roles";
+
+DROP TABLE IF EXISTS "permissions";
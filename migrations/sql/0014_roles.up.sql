@@ -0,0 +1,83 @@
+-- the roles and the permissions replace the is_admin column... every permission
+-- and every built-in role is seeded here, the admin role gets every permission
+-- there is... the ids are fixed so they are the same in every database, the rows
+-- that already exist (e.g. seeded before the migrations) are left as they are
+
+CREATE TABLE IF NOT EXISTS "permissions" (
+    "id" bytea NOT NULL,
+    "name" text NOT NULL UNIQUE,
+    "description" text,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "roles" (
+    "id" bytea NOT NULL,
+    "name" text NOT NULL UNIQUE,
+    "description" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "role_permissions" (
+    "role_id" bytea NOT NULL,
+    "permission_id" bytea NOT NULL,
+    PRIMARY KEY ("role_id","permission_id"),
+    CONSTRAINT "fk_role_permissions_permission" FOREIGN KEY ("permission_id") REFERENCES "permissions"("id") ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT "fk_role_permissions_role" FOREIGN KEY ("role_id") REFERENCES "roles"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS "user_roles" (
+    "user_id" bytea NOT NULL,
+    "role_id" bytea NOT NULL,
+    PRIMARY KEY ("user_id","role_id"),
+    CONSTRAINT "fk_user_roles_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT "fk_user_roles_role" FOREIGN KEY ("role_id") REFERENCES "roles"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+INSERT INTO "permissions" ("id", "name", "description") VALUES
+    ('dba705v4vac8pffbhua0'::bytea, 'products:write', 'Add, update and delete products'),
+    ('dba705v4vac8pffbhuag'::bytea, 'categories:write', 'Add, update and delete categories'),
+    ('dba705v4vac8pffbhub0'::bytea, 'orders:read', 'Read the orders of every user'),
+    ('dba705v4vac8pffbhubg'::bytea, 'orders:refund', 'Refund payments'),
+    ('dba705v4vac8pffbhuc0'::bytea, 'payments:manage', 'Read and reprocess payment events'),
+    ('dba705v4vac8pffbhucg'::bytea, 'reviews:moderate', 'Hide, unhide and delete reviews'),
+    ('dba705v4vac8pffbhud0'::bytea, 'users:read', 'Read the data of every user'),
+    ('dba705v4vac8pffbhudg'::bytea, 'users:write', 'Manage the accounts of every user'),
+    ('dba705v4vac8pffbhue0'::bytea, 'users:impersonate', 'Act as any other user'),
+    ('dba705v4vac8pffbhueg'::bytea, 'roles:write', 'Grant and revoke roles'),
+    ('dba705v4vac8pffbhuf0'::bytea, 'audit:read', 'Read the audit logs')
+ON CONFLICT ("name") DO NOTHING;
+
+INSERT INTO "roles" ("id", "name", "created_at", "updated_at") VALUES
+    ('dba705v4vac8pffbhufg'::bytea, 'admin', now(), now()),
+    ('dba705v4vac8pffbhug0'::bytea, 'catalog', now(), now()),
+    ('dba705v4vac8pffbhugg'::bytea, 'support', now(), now())
+ON CONFLICT ("name") DO NOTHING;
+
+-- the names are joined instead of the ids since the rows might have been seeded
+-- with other ids before
+INSERT INTO "role_permissions" ("role_id", "permission_id")
+SELECT r."id", p."id"
+FROM "roles" r
+JOIN "permissions" p ON r."name" = 'admin'
+    OR (r."name" = 'catalog' AND p."name" IN ('products:write', 'categories:write'))
+    OR (r."name" = 'support' AND p."name" IN ('orders:read', 'orders:refund', 'reviews:moderate', 'users:read'))
+ON CONFLICT DO NOTHING;
+
+-- the users that were admins back when it was an is_admin column get the admin
+-- role and then the column is dropped
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'users' AND column_name = 'is_admin'
+    ) THEN
+        INSERT INTO "user_roles" ("user_id", "role_id")
+        SELECT u."id", r."id" FROM "users" u, "roles" r
+        WHERE u."is_admin" AND r."name" = 'admin'
+        ON CONFLICT DO NOTHING;
+
+        ALTER TABLE "users" DROP COLUMN "is_admin";
+    END IF;
+END $$;
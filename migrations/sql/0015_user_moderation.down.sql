@@ -0,0 +1,8 @@
+-- drops the audit logs and the moderation columns of the users
+
+DROP TABLE IF EXISTS "audit_logs";
+
+ALTER TABLE "users"
+    DROP COLUMN IF EXISTS "password_reset_required",
+    DROP COLUMN IF EXISTS "suspension_reason",
+    DROP COLUMN IF EXISTS "suspended_at";
@@ -0,0 +1,25 @@
+-- the admins can suspend the users and force them to reset their passwords, and
+-- everything they do is recorded in the audit logs
+
+ALTER TABLE "users"
+    ADD COLUMN IF NOT EXISTS "suspended_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "suspension_reason" text,
+    ADD COLUMN IF NOT EXISTS "password_reset_required" boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS "audit_logs" (
+    "id" bytea NOT NULL,
+    "actor_id" bytea NOT NULL,
+    "action" text NOT NULL,
+    "target_type" text NOT NULL,
+    "target_id" bytea NOT NULL,
+    "details" text,
+    "ip_address" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_action" ON "audit_logs" ("action");
+
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_actor_id" ON "audit_logs" ("actor_id");
+
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_target_id" ON "audit_logs" ("target_id");
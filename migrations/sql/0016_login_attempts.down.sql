@@ -0,0 +1,3 @@
+-- drops the failed sign in attempts
+
+DROP TABLE IF EXISTS "login_attempts";
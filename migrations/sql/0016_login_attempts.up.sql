@@ -0,0 +1,10 @@
+-- the failed sign in attempts per account and per ip address
+
+CREATE TABLE IF NOT EXISTS "login_attempts" (
+    "key" text,
+    "failures" bigint NOT NULL,
+    "last_failure_at" timestamptz NOT NULL,
+    PRIMARY KEY ("key")
+);
+
+CREATE INDEX IF NOT EXISTS "idx_login_attempts_last_failure_at" ON "login_attempts" ("last_failure_at");
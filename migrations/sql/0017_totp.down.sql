@@ -0,0 +1,10 @@
+-- drops the two-factor authentication
+
+DROP TABLE IF EXISTS "recovery_codes";
+
+ALTER TABLE "sessions" DROP COLUMN IF EXISTS "mfa";
+
+ALTER TABLE "users"
+    DROP COLUMN IF EXISTS "totp_last_step",
+    DROP COLUMN IF EXISTS "totp_enabled_at",
+    DROP COLUMN IF EXISTS "totp_secret";
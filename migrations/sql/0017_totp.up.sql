@@ -0,0 +1,21 @@
+-- the two-factor authentication, the totp secret of every user, the recovery
+-- codes and whether a session was signed in with a second factor
+
+ALTER TABLE "users"
+    ADD COLUMN IF NOT EXISTS "totp_secret" text,
+    ADD COLUMN IF NOT EXISTS "totp_enabled_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "totp_last_step" bigint NOT NULL DEFAULT 0;
+
+ALTER TABLE "sessions" ADD COLUMN IF NOT EXISTS "mfa" boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS "recovery_codes" (
+    "id" bytea NOT NULL,
+    "code_hash" text NOT NULL,
+    "used_at" timestamptz,
+    "created_at" timestamptz,
+    "user_id" bytea NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_recovery_codes_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "idx_recovery_codes_user_id" ON "recovery_codes" ("user_id");
@@ -0,0 +1,3 @@
+-- allows more than one active payment per order again
+
+DROP INDEX IF EXISTS "idx_payments_order_active";
@@ -0,0 +1,6 @@
+-- an order can only have one active payment (neither failed nor refunded) at a
+-- time, so the same order can never be charged twice... this fails if there are
+-- orders that have already been charged twice, they must be refunded first
+
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_payments_order_active" ON "payments" ("order_id")
+    WHERE "status" IN ('pending', 'authorized', 'captured', 'refunding');
@@ -0,0 +1,4 @@
+-- drops the time of the failure before the last one
+
+ALTER TABLE "login_attempts"
+    DROP COLUMN IF EXISTS "previous_failure_at";
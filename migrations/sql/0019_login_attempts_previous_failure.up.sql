@@ -0,0 +1,5 @@
+-- the attempts are counted before the password is compared, so the time of the
+-- failure before the last one is kept to be able to undo the last one
+
+ALTER TABLE "login_attempts"
+    ADD COLUMN IF NOT EXISTS "previous_failure_at" timestamptz;
@@ -0,0 +1,2 @@
+-- the backfilled snapshots are kept, there is no telling them apart from the
+-- ones taken when the products were wishlisted
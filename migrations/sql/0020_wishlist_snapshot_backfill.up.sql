@@ -0,0 +1,11 @@
+-- the wishlist items from before the snapshots were taken got a zero snapshot,
+-- which would tell every user their products are back in stock... they get the
+-- product as it is now instead (their created_at is left null since there is no
+-- telling when they were wishlisted)
+
+UPDATE "user_wishlist_products" AS "wp"
+SET "price" = "p"."price",
+    "discount" = "p"."discount",
+    "quantity" = "p"."quantity"
+FROM "products" AS "p"
+WHERE "wp"."product_id" = "p"."id" AND "wp"."created_at" IS NULL;
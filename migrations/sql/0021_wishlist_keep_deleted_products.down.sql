@@ -0,0 +1,17 @@
+-- the wishlist items of the deleted products go away, there is no product to
+-- put back in the primary key
+
+DELETE FROM "user_wishlist_products" WHERE "product_id" IS NULL;
+
+ALTER TABLE "user_wishlist_products"
+    DROP CONSTRAINT IF EXISTS "fk_user_wishlist_products_product",
+    ADD CONSTRAINT "fk_user_wishlist_products_product" FOREIGN KEY ("product_id") REFERENCES "products"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+DROP INDEX IF EXISTS "idx_user_wishlist_products_user_product";
+
+ALTER TABLE "user_wishlist_products"
+    ALTER COLUMN "product_id" SET NOT NULL,
+    ADD PRIMARY KEY ("product_id", "user_id");
+
+ALTER TABLE "user_wishlist_products"
+    DROP COLUMN IF EXISTS "name";
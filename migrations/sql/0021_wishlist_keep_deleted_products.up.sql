@@ -0,0 +1,24 @@
+-- the wishlist items outlive their products so the users can be told the product
+-- is no longer available, the product reference is nulled instead and the name
+-- is kept in the snapshot to tell which product it was
+
+ALTER TABLE "user_wishlist_products"
+    ADD COLUMN IF NOT EXISTS "name" text NOT NULL DEFAULT '';
+
+UPDATE "user_wishlist_products" AS "wp"
+SET "name" = "p"."name"
+FROM "products" AS "p"
+WHERE "wp"."product_id" = "p"."id";
+
+-- a null product id can't be a part of the primary key, the same product still
+-- can't be wishlisted twice by the same user though
+ALTER TABLE "user_wishlist_products"
+    DROP CONSTRAINT IF EXISTS "user_wishlist_products_pkey",
+    ALTER COLUMN "product_id" DROP NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_user_wishlist_products_user_product"
+    ON "user_wishlist_products" ("user_id", "product_id");
+
+ALTER TABLE "user_wishlist_products"
+    DROP CONSTRAINT IF EXISTS "fk_user_wishlist_products_product",
+    ADD CONSTRAINT "fk_user_wishlist_products_product" FOREIGN KEY ("product_id") REFERENCES "products"("id") ON DELETE SET NULL ON UPDATE CASCADE;
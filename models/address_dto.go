@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// the country is an ISO 3166-1 alpha-2 code (e.g. ID or US), the zip code is
+// checked against the format of that country
+type AddressDto struct {
+	AddressName         string `json:"address_name" binding:"required,max=32"`
+	ReceiverName        string `json:"receiver_name" binding:"required,max=32"`
+	ReceiverPhoneNumber string `json:"receiver_phone_number" binding:"required,phone"`
+	StreetAddress       string `json:"street_address" binding:"required,max=64"`
+	City                string `json:"city" binding:"required,max=64"`
+	Province            string `json:"province" binding:"required,max=64"`
+	Country             string `json:"country" binding:"required,iso3166_1_alpha2"`
+	ZipCode             string `json:"zip_code" binding:"required,zip_code=Country"`
+}
+
+// an address as it is shown to its owner, either on its own or along with an order
+type AddressDetail struct {
+	ID                  xid.ID    `json:"id"`
+	AddressName         string    `json:"address_name"`
+	ReceiverName        string    `json:"receiver_name"`
+	ReceiverPhoneNumber string    `json:"receiver_phone_number"`
+	StreetAddress       string    `json:"street_address"`
+	City                string    `json:"city"`
+	Province            string    `json:"province"`
+	Country             string    `json:"country"`
+	ZipCode             string    `json:"zip_code"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+func NewAddressDetail(address *Address) AddressDetail {
+	return AddressDetail{
+		ID:                  address.ID,
+		AddressName:         address.AddressName,
+		ReceiverName:        address.ReceiverName,
+		ReceiverPhoneNumber: address.ReceiverPhoneNumber,
+		StreetAddress:       address.StreetAddress,
+		City:                address.City,
+		Province:            address.Province,
+		Country:             address.Country,
+		ZipCode:             address.ZipCode,
+		CreatedAt:           address.CreatedAt,
+		UpdatedAt:           address.UpdatedAt,
+	}
+}
+
+func NewAddressDetails(addresses []Address) []AddressDetail {
+	details := []AddressDetail{}
+	for i := range addresses {
+		details = append(details, NewAddressDetail(&addresses[i]))
+	}
+
+	return details
+}
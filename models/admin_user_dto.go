@@ -0,0 +1,9 @@
+package models
+
+type SuspendUserDto struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+type UserRoleDto struct {
+	Role string `json:"role" binding:"required"`
+}
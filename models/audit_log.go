@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+const (
+	AuditActionUserSuspend       = "user.suspend"
+	AuditActionUserUnsuspend     = "user.unsuspend"
+	AuditActionUserPasswordReset = "user.force_password_reset"
+	AuditActionUserRoleGrant     = "user.role_grant"
+	AuditActionUserRoleRevoke    = "user.role_revoke"
+	AuditActionUserImpersonate   = "user.impersonate"
+	// a request made with an impersonation token, the actor is the impersonator
+	AuditActionImpersonatedRequest = "user.impersonated_request"
+)
+
+// a record of something an admin has done to a user, the records are never
+// updated nor deleted (not even when the actor or the target is deleted, which
+// is why they aren't foreign keys)
+type AuditLog struct {
+	ID         xid.ID    `gorm:"<-:create;primarykey;not null" json:"id"`
+	ActorID    xid.ID    `gorm:"not null;index" json:"actor_id"`
+	Action     string    `gorm:"not null;index" json:"action"`
+	TargetType string    `gorm:"not null" json:"target_type"`
+	TargetID   xid.ID    `gorm:"not null;index" json:"target_id"`
+	Details    string    `gorm:"type:text" json:"details,omitempty"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (al *AuditLog) BeforeCreate(tx *gorm.DB) error {
+	al.ID = xid.New()
+	return nil
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// the details are the json the action was recorded with, they are sent as they
+// were stored
+type AuditLogDetail struct {
+	ID         xid.ID    `json:"id"`
+	ActorID    xid.ID    `json:"actor_id"`
+	Action     string    `json:"action"`
+	TargetType string    `json:"target_type"`
+	TargetID   xid.ID    `json:"target_id"`
+	Details    string    `json:"details,omitempty"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func NewAuditLogDetails(auditLogs []AuditLog) []AuditLogDetail {
+	details := []AuditLogDetail{}
+	for _, auditLog := range auditLogs {
+		details = append(details, AuditLogDetail{
+			ID:         auditLog.ID,
+			ActorID:    auditLog.ActorID,
+			Action:     auditLog.Action,
+			TargetType: auditLog.TargetType,
+			TargetID:   auditLog.TargetID,
+			Details:    auditLog.Details,
+			IPAddress:  auditLog.IPAddress,
+			CreatedAt:  auditLog.CreatedAt,
+		})
+	}
+
+	return details
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+// every user has at most one cart, it is created the first time it is needed
+type Cart struct {
+	ID        xid.ID    `gorm:"<-:create;primarykey;not null" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID xid.ID     `gorm:"not null;uniqueIndex" json:"-"`
+	Items  []CartItem `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"items,omitempty"`
+}
+
+func (c *Cart) BeforeCreate(tx *gorm.DB) error {
+	c.ID = xid.New()
+	return nil
+}
+
+// a product can only appear once in a cart, adding it again increases the quantity instead
+type CartItem struct {
+	ID        xid.ID    `gorm:"<-:create;primarykey;not null" json:"id"`
+	Quantity  uint32    `gorm:"not null" json:"quantity"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	CartID    xid.ID   `gorm:"not null;uniqueIndex:idx_cart_items_cart_product" json:"-"`
+	ProductID xid.ID   `gorm:"not null;uniqueIndex:idx_cart_items_cart_product" json:"product_id"`
+	Product   *Product `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"product,omitempty"`
+}
+
+func (ci *CartItem) BeforeCreate(tx *gorm.DB) error {
+	ci.ID = xid.New()
+	return nil
+}
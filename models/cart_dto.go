@@ -0,0 +1,82 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/rs/xid"
+)
+
+type CartItemDto struct {
+	ProductID xid.ID `json:"product_id" binding:"required"`
+	Quantity  uint32 `json:"quantity" binding:"required,min=1,max=10000"`
+}
+
+type CartItemQuantityDto struct {
+	Quantity uint32 `json:"quantity" binding:"required,min=1,max=10000"`
+}
+
+// the totals of a cart are always computed on the server from the current
+// price and discount of the products, the client should never compute them
+type CartSummary struct {
+	ID          xid.ID     `json:"id"`
+	Items       []CartLine `json:"items"`
+	TotalItems  uint32     `json:"total_items"`
+	Total       uint64     `json:"total"`
+	HasWarnings bool       `json:"has_warnings"`
+}
+
+type CartLine struct {
+	ProductID         xid.ID `json:"product_id"`
+	Name              string `json:"name"`
+	Price             uint32 `json:"price"`
+	Discount          uint8  `json:"discount"`
+	UnitPrice         uint32 `json:"unit_price"`
+	Quantity          uint32 `json:"quantity"`
+	LineTotal         uint64 `json:"line_total"`
+	AvailableQuantity uint32 `json:"available_quantity"`
+	InStock           bool   `json:"in_stock"`
+	Warning           string `json:"warning,omitempty"`
+}
+
+func NewCartSummary(cart *Cart) CartSummary {
+	summary := CartSummary{
+		ID:    cart.ID,
+		Items: []CartLine{},
+	}
+
+	for _, item := range cart.Items {
+		if item.Product == nil {
+			continue
+		}
+
+		line := CartLine{
+			ProductID:         item.ProductID,
+			Name:              item.Product.Name,
+			Price:             item.Product.Price,
+			Discount:          item.Product.Discount,
+			UnitPrice:         item.Product.DiscountedPrice(),
+			Quantity:          item.Quantity,
+			AvailableQuantity: item.Product.Quantity,
+			InStock:           item.Product.Quantity > 0,
+		}
+		line.LineTotal = uint64(line.UnitPrice) * uint64(line.Quantity)
+
+		// the stock might have changed since the item was added to the cart
+		switch {
+		case !line.InStock:
+			line.Warning = "Product is out of stock"
+		case line.Quantity > line.AvailableQuantity:
+			line.Warning = fmt.Sprintf("Only %d left in stock", line.AvailableQuantity)
+		}
+
+		if line.Warning != "" {
+			summary.HasWarnings = true
+		}
+
+		summary.Items = append(summary.Items, line)
+		summary.TotalItems += line.Quantity
+		summary.Total += line.LineTotal
+	}
+
+	return summary
+}
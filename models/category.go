@@ -9,12 +9,14 @@ import (
 )
 
 type Category struct {
-	ID          xid.ID    `gorm:"<-:create;primarykey;not null;unique" json:"id"`
-	Name        string    `gorm:"not null;unique" json:"name"`
-	Description string    `json:"description,omitempty"`
-	Slug        string    `gorm:"not null;unique" json:"slug"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          xid.ID `gorm:"<-:create;primarykey;not null;unique" json:"id"`
+	Name        string `gorm:"not null;unique" json:"name"`
+	Description string `json:"description,omitempty"`
+	Slug        string `gorm:"not null;unique" json:"slug"`
+	// only set when it is asked for (with ?expand=products_count)
+	ProductsCount *int64    `gorm:"->;-:migration" json:"-"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
 
 	Products []*Product `gorm:"many2many:product_categories" json:"products,omitempty"`
 }
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+)
+
+type CategoryDto struct {
+	Name        string `json:"name" binding:"required,max=64"`
+	Description string `json:"description" binding:"max=255"`
+}
+
+type CategorySummary struct {
+	ID   xid.ID `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// the products (and their count) are only there when they are asked for
+type CategoryDetail struct {
+	ID            xid.ID           `json:"id"`
+	Name          string           `json:"name"`
+	Description   string           `json:"description,omitempty"`
+	Slug          string           `json:"slug"`
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+	Products      []ProductSummary `json:"products,omitempty"`
+	ProductsCount *int64           `json:"products_count,omitempty"`
+}
+
+func NewCategorySummaries(categories []*Category) []CategorySummary {
+	var summaries []CategorySummary
+	for _, category := range categories {
+		summaries = append(summaries, CategorySummary{
+			ID:   category.ID,
+			Name: category.Name,
+			Slug: category.Slug,
+		})
+	}
+
+	return summaries
+}
+
+func NewCategoryDetail(category *Category) CategoryDetail {
+	detail := CategoryDetail{
+		ID:          category.ID,
+		Name:        category.Name,
+		Description: category.Description,
+		Slug:        category.Slug,
+		CreatedAt:   category.CreatedAt,
+		UpdatedAt:   category.UpdatedAt,
+
+		ProductsCount: category.ProductsCount,
+	}
+
+	for _, product := range category.Products {
+		detail.Products = append(detail.Products, NewProductSummary(product))
+	}
+
+	return detail
+}
+
+func NewCategoryDetails(categories []Category) []CategoryDetail {
+	details := []CategoryDetail{}
+	for i := range categories {
+		details = append(details, NewCategoryDetail(&categories[i]))
+	}
+
+	return details
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// the sign in attempts of a single key (an email address or an ip address) that
+// haven't succeeded, the counter starts over once the last one is old enough...
+// the time of the one before the last is kept so the last one can be undone
+type LoginAttempt struct {
+	Key               string     `gorm:"primarykey" json:"key"`
+	Failures          int        `gorm:"not null" json:"failures"`
+	LastFailureAt     time.Time  `gorm:"not null;index" json:"last_failure_at"`
+	PreviousFailureAt *time.Time `json:"previous_failure_at"`
+}
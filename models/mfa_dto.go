@@ -0,0 +1,22 @@
+package models
+
+// the current password is asked for before enrolling, otherwise a stolen access
+// token would be enough to lock the owner out with a second factor of its own
+type EnrollTotpDto struct {
+	Password string `json:"password" binding:"required"`
+}
+
+type TotpCodeDto struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// either the totp code or one of the recovery codes
+type MfaCodeDto struct {
+	Code         string `json:"code" binding:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type MfaSignInDto struct {
+	MfaToken string `json:"mfa_token" binding:"required"`
+	MfaCodeDto
+}
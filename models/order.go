@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+const (
+	OrderStatusPending   = "pending"
+	OrderStatusPaid      = "paid"
+	OrderStatusShipped   = "shipped"
+	OrderStatusCompleted = "completed"
+	OrderStatusCancelled = "cancelled"
+	OrderStatusRefunded  = "refunded"
+)
+
+// an order belongs to a user and is shipped to one of the user's addresses,
+// the address is only referenced here so if the user deletes the address
+// later on, the order is kept but its address reference is set to null
+type Order struct {
+	ID         xid.ID    `gorm:"<-:create;primarykey;not null" json:"id"`
+	Status     string    `gorm:"not null;size:16;index" json:"status"`
+	TotalPrice uint64    `gorm:"not null" json:"total_price"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	UserID    xid.ID      `gorm:"not null;index" json:"user_id"`
+	AddressID xid.ID      `json:"address_id"`
+	Address   *Address    `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"address,omitempty"`
+	Items     []OrderItem `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"items,omitempty"`
+}
+
+func (o *Order) BeforeCreate(tx *gorm.DB) error {
+	o.ID = xid.New()
+	return nil
+}
+
+// the name, price and discount of the product are copied into the order item
+// when the order is placed, that way later changes to the product won't affect
+// the orders that have already been made
+type OrderItem struct {
+	ID        xid.ID    `gorm:"<-:create;primarykey;not null" json:"id"`
+	Name      string    `gorm:"not null" json:"name"`
+	Quantity  uint32    `gorm:"not null" json:"quantity"`
+	Price     uint32    `gorm:"not null" json:"price"`
+	Discount  uint8     `json:"discount"`
+	Subtotal  uint64    `gorm:"not null" json:"subtotal"`
+	CreatedAt time.Time `json:"created_at"`
+
+	OrderID   xid.ID   `gorm:"not null;index" json:"-"`
+	ProductID xid.ID   `json:"product_id"`
+	Product   *Product `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"product,omitempty"`
+}
+
+func (oi *OrderItem) BeforeCreate(tx *gorm.DB) error {
+	oi.ID = xid.New()
+	return nil
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// the most units of a single product that can be ordered (or reserved, or put in
+// a cart) at once, the max rules of the quantities must be kept in sync with it
+const MaxItemQuantity = 10000
+
+// the client only sends the product ids and the quantities, everything else
+// (price, discount, totals) is taken from the database when the order is placed
+//
+// instead of the items, the id of a pending reservation can be sent as well,
+// in that case the order is made of the reserved items and the reservation is
+// committed once the order is placed
+type OrderDto struct {
+	AddressID     xid.ID         `json:"address_id" binding:"required"`
+	ReservationID xid.ID         `json:"reservation_id"`
+	Items         []OrderItemDto `json:"items" binding:"required_without=ReservationID,dive"`
+}
+
+type OrderItemDto struct {
+	ProductID xid.ID `json:"product_id" binding:"required"`
+	Quantity  uint32 `json:"quantity" binding:"required,min=1,max=10000"`
+}
+
+// the address is only there when it is loaded along with the order (and when it
+// hasn't been deleted since)
+type OrderDetail struct {
+	ID         xid.ID            `json:"id"`
+	Status     string            `json:"status"`
+	TotalPrice uint64            `json:"total_price"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	UserID     xid.ID            `json:"user_id"`
+	AddressID  xid.ID            `json:"address_id"`
+	Address    *AddressDetail    `json:"address,omitempty"`
+	Items      []OrderItemDetail `json:"items"`
+}
+
+type OrderItemDetail struct {
+	ID        xid.ID    `json:"id"`
+	ProductID xid.ID    `json:"product_id"`
+	Name      string    `json:"name"`
+	Quantity  uint32    `json:"quantity"`
+	Price     uint32    `json:"price"`
+	Discount  uint8     `json:"discount"`
+	Subtotal  uint64    `json:"subtotal"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewOrderDetail(order *Order) OrderDetail {
+	detail := OrderDetail{
+		ID:         order.ID,
+		Status:     order.Status,
+		TotalPrice: order.TotalPrice,
+		CreatedAt:  order.CreatedAt,
+		UpdatedAt:  order.UpdatedAt,
+		UserID:     order.UserID,
+		AddressID:  order.AddressID,
+		Items:      []OrderItemDetail{},
+	}
+
+	if order.Address != nil {
+		address := NewAddressDetail(order.Address)
+		detail.Address = &address
+	}
+
+	for _, item := range order.Items {
+		detail.Items = append(detail.Items, OrderItemDetail{
+			ID:        item.ID,
+			ProductID: item.ProductID,
+			Name:      item.Name,
+			Quantity:  item.Quantity,
+			Price:     item.Price,
+			Discount:  item.Discount,
+			Subtotal:  item.Subtotal,
+			CreatedAt: item.CreatedAt,
+		})
+	}
+
+	return detail
+}
+
+func NewOrderDetails(orders []Order) []OrderDetail {
+	details := []OrderDetail{}
+	for i := range orders {
+		details = append(details, NewOrderDetail(&orders[i]))
+	}
+
+	return details
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+const (
+	PaymentStatusPending    = "pending"
+	PaymentStatusAuthorized = "authorized"
+	PaymentStatusCaptured   = "captured"
+	PaymentStatusRefunding  = "refunding"
+	PaymentStatusRefunded   = "refunded"
+	PaymentStatusFailed     = "failed"
+)
+
+// the statuses a payment can move to from its current status, a payment only
+// ever moves forward: pending -> authorized -> captured -> refunding -> refunded,
+// and it can fail before it is captured... a refund that fails moves the payment
+// back to captured, and a refund made on the provider's side (reported by a
+// webhook) skips the refunding status
+var paymentTransitions = map[string][]string{
+	PaymentStatusPending:    {PaymentStatusAuthorized, PaymentStatusFailed},
+	PaymentStatusAuthorized: {PaymentStatusCaptured, PaymentStatusFailed},
+	PaymentStatusCaptured:   {PaymentStatusRefunding, PaymentStatusRefunded},
+	PaymentStatusRefunding:  {PaymentStatusRefunded, PaymentStatusCaptured},
+}
+
+// a payment belongs to an order, an order can have many payments (e.g. when
+// the first attempt is declined the user can try again) but only one of them can
+// be active (neither failed nor refunded) at a time, there is a partial unique
+// index on the order_id of the active payments for that
+type Payment struct {
+	ID            xid.ID    `gorm:"<-:create;primarykey;not null" json:"id"`
+	Provider      string    `gorm:"not null;size:32" json:"provider"`
+	Reference     string    `gorm:"index" json:"reference,omitempty"`
+	Amount        uint64    `gorm:"not null" json:"amount"`
+	Status        string    `gorm:"not null;size:16;index" json:"status"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+
+	OrderID xid.ID `gorm:"not null;index" json:"order_id"`
+	Order   *Order `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (p *Payment) BeforeCreate(tx *gorm.DB) error {
+	p.ID = xid.New()
+	return nil
+}
+
+func (p *Payment) CanTransitionTo(status string) bool {
+	for _, next := range paymentTransitions[p.Status] {
+		if next == status {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// the provider's reference is only needed to match the webhook events, so it
+// isn't shown
+type PaymentDetail struct {
+	ID            xid.ID    `json:"id"`
+	OrderID       xid.ID    `json:"order_id"`
+	Provider      string    `json:"provider"`
+	Amount        uint64    `json:"amount"`
+	Status        string    `json:"status"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func NewPaymentDetail(payment *Payment) PaymentDetail {
+	return PaymentDetail{
+		ID:            payment.ID,
+		OrderID:       payment.OrderID,
+		Provider:      payment.Provider,
+		Amount:        payment.Amount,
+		Status:        payment.Status,
+		FailureReason: payment.FailureReason,
+		CreatedAt:     payment.CreatedAt,
+		UpdatedAt:     payment.UpdatedAt,
+	}
+}
+
+func NewPaymentDetails(payments []Payment) []PaymentDetail {
+	details := []PaymentDetail{}
+	for i := range payments {
+		details = append(details, NewPaymentDetail(&payments[i]))
+	}
+
+	return details
+}
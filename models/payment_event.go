@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+const (
+	PaymentEventStatusReceived  = "received"
+	PaymentEventStatusProcessed = "processed"
+	PaymentEventStatusIgnored   = "ignored"
+	PaymentEventStatusFailed    = "failed"
+)
+
+// every (verified) webhook event sent by a payment provider is kept as it was
+// received for auditing, the events that failed to be processed can be
+// reprocessed later on from the stored payload
+//
+// the provider's event id is unique per provider, an event that has already
+// been received is a replay and is never processed twice
+type PaymentEvent struct {
+	ID          xid.ID     `gorm:"<-:create;primarykey;not null" json:"id"`
+	Provider    string     `gorm:"not null;size:32;uniqueIndex:idx_payment_events_provider_event" json:"provider"`
+	EventID     string     `gorm:"not null;uniqueIndex:idx_payment_events_provider_event" json:"event_id"`
+	Type        string     `gorm:"not null" json:"type"`
+	Reference   string     `gorm:"index" json:"reference"`
+	Payload     string     `gorm:"not null;type:text" json:"payload"`
+	Signature   string     `gorm:"not null" json:"signature"`
+	Timestamp   time.Time  `gorm:"not null" json:"timestamp"`
+	Status      string     `gorm:"not null;size:16;index" json:"status"`
+	Error       string     `json:"error,omitempty"`
+	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (pe *PaymentEvent) BeforeCreate(tx *gorm.DB) error {
+	pe.ID = xid.New()
+	return nil
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// the stored payload and the signature it came with are only kept to verify the
+// event again when it is reprocessed, so they aren't shown
+type PaymentEventDetail struct {
+	ID          xid.ID     `json:"id"`
+	Provider    string     `json:"provider"`
+	EventID     string     `json:"event_id"`
+	Type        string     `json:"type"`
+	Reference   string     `json:"reference"`
+	Timestamp   time.Time  `json:"timestamp"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func NewPaymentEventDetail(event *PaymentEvent) PaymentEventDetail {
+	return PaymentEventDetail{
+		ID:          event.ID,
+		Provider:    event.Provider,
+		EventID:     event.EventID,
+		Type:        event.Type,
+		Reference:   event.Reference,
+		Timestamp:   event.Timestamp,
+		Status:      event.Status,
+		Error:       event.Error,
+		ProcessedAt: event.ProcessedAt,
+		CreatedAt:   event.CreatedAt,
+	}
+}
+
+func NewPaymentEventDetails(events []PaymentEvent) []PaymentEventDetail {
+	details := []PaymentEventDetail{}
+	for i := range events {
+		details = append(details, NewPaymentEventDetail(&events[i]))
+	}
+
+	return details
+}
@@ -20,7 +20,18 @@ type Product struct {
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 
-	WishlistedBy []*User     `gorm:"many2many:user_wishlist_products" json:"wishlisted_by,omitempty"`
+	// these are aggregated from the visible reviews of the product when it is
+	// queried, they are not stored in the products table
+	AverageRating float64 `gorm:"->;-:migration" json:"average_rating"`
+	ReviewCount   int64   `gorm:"->;-:migration" json:"review_count"`
+	// only set when the products are searched, it is a part of the description
+	// with the searched words highlighted with <mark> tags (and everything else
+	// HTML-escaped)
+	Snippet string `gorm:"->;-:migration" json:"snippet,omitempty"`
+	// only set when it is asked for (with ?expand=wishlisted_by_count)
+	WishlistedByCount *int64 `gorm:"->;-:migration" json:"-"`
+
+	WishlistedBy []*User     `gorm:"many2many:user_wishlist_products" json:"-"`
 	Categories   []*Category `gorm:"many2many:product_categories" json:"categories,omitempty"`
 }
 
@@ -28,3 +39,13 @@ func (p *Product) BeforeCreate(tx *gorm.DB) error {
 	p.ID = xid.New()
 	return nil
 }
+
+// returns the price of a single unit of the product after the discount (in
+// percent) is applied
+func (p *Product) DiscountedPrice() uint32 {
+	if p.Discount >= 100 {
+		return 0
+	}
+
+	return uint32(uint64(p.Price) * uint64(100-p.Discount) / 100)
+}
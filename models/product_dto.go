@@ -1,6 +1,10 @@
 package models
 
-import "github.com/rs/xid"
+import (
+	"time"
+
+	"github.com/rs/xid"
+)
 
 // the reason I make a separate dto for the Product model is to make it easier
 // for the client side to add or remove categories of a product record
@@ -12,8 +16,69 @@ type ProductDto struct {
 	Name        string `json:"name" binding:"required"`
 	Description string `json:"description"`
 	Price       uint32 `json:"price" binding:"required"`
-	Discount    uint8  `json:"discount"`
+	Discount    uint8  `json:"discount" binding:"max=100"`
 	Quantity    uint32 `json:"quantity" binding:"required"`
 
 	Categories []xid.ID `json:"categories"`
 }
+
+// a product as it is shown in the lists (e.g. the search results or a category),
+// the exact stock is only shown on the product's own page
+type ProductSummary struct {
+	ID            xid.ID  `json:"id"`
+	Name          string  `json:"name"`
+	Price         uint32  `json:"price"`
+	Discount      uint8   `json:"discount"`
+	InStock       bool    `json:"in_stock"`
+	AverageRating float64 `json:"average_rating"`
+	ReviewCount   int64   `json:"review_count"`
+	Snippet       string  `json:"snippet,omitempty"`
+
+	// the expansions, they are only there when they are asked for
+	Categories        []CategorySummary `json:"categories,omitempty"`
+	WishlistedByCount *int64            `json:"wishlisted_by_count,omitempty"`
+}
+
+// who wishlisted the product is nobody else's business, so it is never shown
+type ProductDetail struct {
+	ProductSummary
+	Description string    `json:"description"`
+	Quantity    uint32    `json:"quantity"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func NewProductSummary(product *Product) ProductSummary {
+	return ProductSummary{
+		ID:            product.ID,
+		Name:          product.Name,
+		Price:         product.Price,
+		Discount:      product.Discount,
+		InStock:       product.Quantity > 0,
+		AverageRating: product.AverageRating,
+		ReviewCount:   product.ReviewCount,
+		Snippet:       product.Snippet,
+		Categories:    NewCategorySummaries(product.Categories),
+
+		WishlistedByCount: product.WishlistedByCount,
+	}
+}
+
+func NewProductSummaries(products []Product) []ProductSummary {
+	summaries := []ProductSummary{}
+	for i := range products {
+		summaries = append(summaries, NewProductSummary(&products[i]))
+	}
+
+	return summaries
+}
+
+func NewProductDetail(product *Product) ProductDetail {
+	return ProductDetail{
+		ProductSummary: NewProductSummary(product),
+		Description:    product.Description,
+		Quantity:       product.Quantity,
+		CreatedAt:      product.CreatedAt,
+		UpdatedAt:      product.UpdatedAt,
+	}
+}
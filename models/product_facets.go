@@ -0,0 +1,39 @@
+package models
+
+import "github.com/rs/xid"
+
+// the upper bounds (exclusive) of the price buckets of the product facets, the
+// last bucket has no upper bound
+var PriceBucketBounds = []uint32{50000, 100000, 250000, 500000, 1000000}
+
+// the number of products matching the current filters, broken down by some of
+// their attributes so the storefront can show how many products each
+// additional filter would leave
+type ProductFacets struct {
+	Categories   []CategoryFacet `json:"categories"`
+	PriceBuckets []PriceBucket   `json:"price_buckets"`
+	Stock        StockFacet      `json:"stock"`
+}
+
+type CategoryFacet struct {
+	Slug  string `json:"slug"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+type PriceBucket struct {
+	Min   uint32  `json:"min"`
+	Max   *uint32 `json:"max,omitempty"`
+	Count int64   `json:"count"`
+}
+
+type StockFacet struct {
+	InStock    int64 `json:"in_stock"`
+	OutOfStock int64 `json:"out_of_stock"`
+}
+
+type ProductSuggestion struct {
+	ID    xid.ID  `json:"id"`
+	Name  string  `json:"name"`
+	Score float64 `json:"score"`
+}
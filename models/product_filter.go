@@ -0,0 +1,34 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// the filters and the sorting of the product list, they are bound from the
+// query params of the request and echoed back in the response so the client
+// knows which of them have been applied
+type ProductFilter struct {
+	Search       string     `form:"search" json:"search,omitempty"`
+	Categories   []string   `form:"category" json:"category,omitempty"`
+	MinPrice     *uint32    `form:"min_price" json:"min_price,omitempty"`
+	MaxPrice     *uint32    `form:"max_price" json:"max_price,omitempty"`
+	HasDiscount  *bool      `form:"has_discount" json:"has_discount,omitempty"`
+	InStock      *bool      `form:"in_stock" json:"in_stock,omitempty"`
+	CreatedAfter *time.Time `form:"created_after" time_format:"2006-01-02" json:"created_after,omitempty"`
+	Sort         string     `form:"sort" binding:"omitempty,oneof=price -price created_at -created_at name -name rating -rating" json:"sort,omitempty"`
+}
+
+// the products are sorted by their ids unless they are explicitly sorted or
+// searched (which sorts them by their relevance)
+func (f *ProductFilter) IsSortedById() bool {
+	return f.Sort == "" && f.Search == ""
+}
+
+func (f *ProductFilter) Validate() error {
+	if f.MinPrice != nil && f.MaxPrice != nil && *f.MinPrice > *f.MaxPrice {
+		return errors.New("min_price cannot be greater than max_price")
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+// a one-time code that can be used instead of a totp code (e.g. when the phone
+// is lost), only its bcrypt hash is stored
+type RecoveryCode struct {
+	ID        xid.ID     `gorm:"<-:create;primarykey;not null" json:"id"`
+	CodeHash  string     `gorm:"not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	UserID xid.ID `gorm:"not null;index" json:"-"`
+	User   *User  `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (rc *RecoveryCode) BeforeCreate(tx *gorm.DB) error {
+	rc.ID = xid.New()
+	return nil
+}
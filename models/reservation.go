@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+const (
+	ReservationStatusPending   = "pending"
+	ReservationStatusCommitted = "committed"
+	ReservationStatusReleased  = "released"
+)
+
+// a reservation holds some stock of one or more products for a limited time,
+// the stock is taken from the products as soon as the reservation is made and
+// it is given back if the reservation is released or is not committed before
+// it expires
+type Reservation struct {
+	ID        xid.ID    `gorm:"<-:create;primarykey;not null" json:"id"`
+	Status    string    `gorm:"not null;size:16;index" json:"status"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID xid.ID            `gorm:"not null;index" json:"-"`
+	Items  []ReservationItem `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"items"`
+}
+
+func (r *Reservation) BeforeCreate(tx *gorm.DB) error {
+	r.ID = xid.New()
+	return nil
+}
+
+type ReservationItem struct {
+	ID       xid.ID `gorm:"<-:create;primarykey;not null" json:"-"`
+	Quantity uint32 `gorm:"not null" json:"quantity"`
+
+	ReservationID xid.ID `gorm:"not null;index" json:"-"`
+	ProductID     xid.ID `gorm:"not null" json:"product_id"`
+}
+
+func (ri *ReservationItem) BeforeCreate(tx *gorm.DB) error {
+	ri.ID = xid.New()
+	return nil
+}
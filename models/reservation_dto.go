@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+)
+
+type ReservationDto struct {
+	Items []ReservationItemDto `json:"items" binding:"required,min=1,dive"`
+}
+
+type ReservationItemDto struct {
+	ProductID xid.ID `json:"product_id" binding:"required"`
+	Quantity  uint32 `json:"quantity" binding:"required,min=1,max=10000"`
+}
+
+type ReservationDetail struct {
+	ID        xid.ID                  `json:"id"`
+	Status    string                  `json:"status"`
+	ExpiresAt time.Time               `json:"expires_at"`
+	CreatedAt time.Time               `json:"created_at"`
+	UpdatedAt time.Time               `json:"updated_at"`
+	Items     []ReservationItemDetail `json:"items"`
+}
+
+type ReservationItemDetail struct {
+	ProductID xid.ID `json:"product_id"`
+	Quantity  uint32 `json:"quantity"`
+}
+
+func NewReservationDetail(reservation *Reservation) ReservationDetail {
+	detail := ReservationDetail{
+		ID:        reservation.ID,
+		Status:    reservation.Status,
+		ExpiresAt: reservation.ExpiresAt,
+		CreatedAt: reservation.CreatedAt,
+		UpdatedAt: reservation.UpdatedAt,
+		Items:     []ReservationItemDetail{},
+	}
+
+	for _, item := range reservation.Items {
+		detail.Items = append(detail.Items, ReservationItemDetail{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+		})
+	}
+
+	return detail
+}
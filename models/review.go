@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+// a review belongs to a product and to the user who wrote it, a user can only
+// review a product once (but can edit the review later), hidden reviews are
+// moderated by an admin and are not shown or counted in the product's rating
+type Review struct {
+	ID        xid.ID    `gorm:"<-:create;primarykey;not null" json:"id"`
+	Rating    uint8     `gorm:"not null" json:"rating"`
+	Title     string    `gorm:"size:64" json:"title"`
+	Body      string    `gorm:"type:text" json:"body"`
+	IsHidden  bool      `gorm:"not null;default:false" json:"is_hidden"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID    xid.ID   `gorm:"not null;uniqueIndex:idx_reviews_user_product" json:"user_id"`
+	ProductID xid.ID   `gorm:"not null;uniqueIndex:idx_reviews_user_product;index" json:"product_id"`
+	User      *User    `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"user,omitempty"`
+	Product   *Product `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (r *Review) BeforeCreate(tx *gorm.DB) error {
+	r.ID = xid.New()
+	return nil
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+)
+
+type ReviewDto struct {
+	Rating uint8  `json:"rating" binding:"required,min=1,max=5"`
+	Title  string `json:"title" binding:"max=64"`
+	Body   string `json:"body"`
+}
+
+// the author of a review is only shown by the username
+type ReviewDetail struct {
+	ID        xid.ID      `json:"id"`
+	Rating    uint8       `json:"rating"`
+	Title     string      `json:"title"`
+	Body      string      `json:"body"`
+	IsHidden  bool        `json:"is_hidden"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	ProductID xid.ID      `json:"product_id"`
+	UserID    xid.ID      `json:"user_id"`
+	User      *PublicUser `json:"user,omitempty"`
+}
+
+func NewReviewDetail(review *Review) ReviewDetail {
+	return ReviewDetail{
+		ID:        review.ID,
+		Rating:    review.Rating,
+		Title:     review.Title,
+		Body:      review.Body,
+		IsHidden:  review.IsHidden,
+		CreatedAt: review.CreatedAt,
+		UpdatedAt: review.UpdatedAt,
+		ProductID: review.ProductID,
+		UserID:    review.UserID,
+		User:      NewPublicUser(review.User),
+	}
+}
+
+func NewReviewDetails(reviews []Review) []ReviewDetail {
+	details := []ReviewDetail{}
+	for i := range reviews {
+		details = append(details, NewReviewDetail(&reviews[i]))
+	}
+
+	return details
+}
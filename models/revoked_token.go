@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// a single access token (identified by its jti claim) that has been revoked before
+// it expired, the record is only needed until then
+type RevokedToken struct {
+	Jti       string    `gorm:"primarykey" json:"jti"`
+	UserID    xid.ID    `gorm:"not null;index" json:"user_id"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+// the permissions are named "<resource>:<action>", a handler (or a route) only
+// checks the permission it needs and never which roles the user has... they are
+// seeded by the migrations along with the built-in roles below, so a new one
+// needs a new migration too
+const (
+	PermissionProductsWrite    = "products:write"
+	PermissionCategoriesWrite  = "categories:write"
+	PermissionOrdersRead       = "orders:read"
+	PermissionOrdersRefund     = "orders:refund"
+	PermissionPaymentsManage   = "payments:manage"
+	PermissionReviewsModerate  = "reviews:moderate"
+	PermissionUsersRead        = "users:read"
+	PermissionUsersWrite       = "users:write"
+	PermissionUsersImpersonate = "users:impersonate"
+	PermissionRolesWrite       = "roles:write"
+	PermissionAuditRead        = "audit:read"
+)
+
+const (
+	RoleAdmin   = "admin"
+	RoleCatalog = "catalog"
+	RoleSupport = "support"
+)
+
+type Role struct {
+	ID          xid.ID    `gorm:"<-:create;primarykey;not null" json:"id"`
+	Name        string    `gorm:"not null;unique" json:"name"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	Permissions []Permission `gorm:"many2many:role_permissions;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"permissions,omitempty"`
+}
+
+func (r *Role) BeforeCreate(tx *gorm.DB) error {
+	r.ID = xid.New()
+	return nil
+}
+
+type Permission struct {
+	ID          xid.ID `gorm:"<-:create;primarykey;not null" json:"id"`
+	Name        string `gorm:"not null;unique" json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+func (p *Permission) BeforeCreate(tx *gorm.DB) error {
+	p.ID = xid.New()
+	return nil
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+// a session holds a (hashed) refresh token, every time the refresh token is used
+// it is rotated: the session is marked as rotated and a new session with a new
+// refresh token is created in the same family... all the sessions that descend
+// from the same sign in share the same family id so when an already rotated
+// refresh token is used again (which means it has been stolen) the whole family
+// can be revoked at once
+type Session struct {
+	ID        xid.ID `gorm:"<-:create;primarykey;not null" json:"id"`
+	FamilyID  xid.ID `gorm:"not null;index" json:"family_id"`
+	TokenHash string `gorm:"not null;uniqueIndex" json:"-"`
+	UserAgent string `json:"user_agent"`
+	IPAddress string `json:"ip_address"`
+	// whether the session was signed in with a second factor
+	Mfa       bool       `gorm:"not null;default:false" json:"mfa"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	UserID xid.ID `gorm:"not null;index" json:"-"`
+	User   *User  `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (s *Session) BeforeCreate(tx *gorm.DB) error {
+	s.ID = xid.New()
+	// the first session of a family is the one that names it
+	if s.FamilyID.IsNil() {
+		s.FamilyID = s.ID
+	}
+	return nil
+}
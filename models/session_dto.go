@@ -0,0 +1,5 @@
+package models
+
+type RefreshTokenDto struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
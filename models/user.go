@@ -1,6 +1,7 @@
 package models
 
 import (
+	"sort"
 	"time"
 
 	"github.com/rs/xid"
@@ -11,13 +12,69 @@ type User struct {
 	ID        xid.ID    `gorm:"<-:create;primarykey;not null" json:"id"`
 	Username  string    `gorm:"not null;unique;size:24" json:"username"`
 	Email     string    `gorm:"not null;unique;" json:"email"`
-	Password  string    `gorm:"not null" json:"password"`
-	IsAdmin   bool      `gorm:"not null" json:"is_admin"`
+	Password  string    `gorm:"not null" json:"-"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 
+	// the state the server keeps about the user is never bound from (nor rendered
+	// as) json, otherwise a client could e.g. mark its own email as verified... the
+	// response types in user_dto.go expose it to the ones who may see it
+	EmailVerifiedAt *time.Time `json:"-"`
+
+	// suspended users can't sign in, neither can the users that have been told to
+	// reset their passwords until they have done so
+	SuspendedAt           *time.Time `json:"-"`
+	SuspensionReason      string     `json:"-"`
+	PasswordResetRequired bool       `gorm:"not null;default:false" json:"-"`
+
+	// the totp secret is set on enrollment but two-factor authentication is only
+	// enabled once it is confirmed with a code, the last step is the time step of
+	// the last code that was used so no code can be used twice
+	TotpSecret    string     `json:"-"`
+	TotpEnabledAt *time.Time `json:"-"`
+	TotpLastStep  int64      `gorm:"not null;default:0" json:"-"`
+
+	// every token carries the generation of its user at the time it was issued,
+	// bumping it revokes all of the user's tokens at once
+	TokenGeneration uint `gorm:"not null;default:0" json:"-"`
+
 	Addresses []Address  `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"addresses,omitempty"`
 	Wishlist  []*Product `gorm:"many2many:user_wishlist_products" json:"wishlist,omitempty"`
+	Roles     []Role     `gorm:"many2many:user_roles;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"roles,omitempty"`
+}
+
+// the names of all the permissions the user has through the roles, the roles
+// must be loaded along with their permissions
+func (u *User) PermissionNames() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, role := range u.Roles {
+		for _, permission := range role.Permissions {
+			if !seen[permission.Name] {
+				seen[permission.Name] = true
+				names = append(names, permission.Name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// two-factor authentication is mandatory for the admins, that is for everyone
+// that has been granted a role
+func (u *User) RequiresMfa() bool {
+	return len(u.Roles) > 0
+}
+
+func (u *User) RoleNames() []string {
+	var names []string
+	for _, role := range u.Roles {
+		names = append(names, role.Name)
+	}
+
+	sort.Strings(names)
+	return names
 }
 
 func (u *User) BeforeCreate(tx *gorm.DB) error {
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// the passwords are hashed with bcrypt which only looks at the first 72 bytes
+type SignUpDto struct {
+	Username string `json:"username" binding:"required,username"`
+	Email    string `json:"email" binding:"required,email,max=255"`
+	Password string `json:"password" binding:"required,min=8,max=72"`
+}
+
+// no rules for the password other than being there, the accounts that were made
+// before there were any rules must still be able to sign in
+type SignInDto struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+// only the fields that are sent are updated, changing the email asks for the
+// current password as well
+type UpdateUserDto struct {
+	Username        *string `json:"username" binding:"omitempty,username"`
+	Email           *string `json:"email" binding:"omitempty,email,max=255"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+type UpdatePasswordDto struct {
+	Password string `json:"password" binding:"required,min=8,max=72"`
+}
+
+// the users are never sent as they are stored, each audience gets its own view
+// of them... this is what anyone can see, e.g. the author of a review
+type PublicUser struct {
+	ID       xid.ID `json:"id"`
+	Username string `json:"username"`
+}
+
+// what the users see about themselves
+type SelfUser struct {
+	ID              xid.ID     `json:"id"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	TotpEnabledAt   *time.Time `json:"totp_enabled_at"`
+	Roles           []string   `json:"roles"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// what the admins see about a user, which is everything but the secrets
+type AdminUser struct {
+	SelfUser
+	Permissions           []string   `json:"permissions"`
+	SuspendedAt           *time.Time `json:"suspended_at"`
+	SuspensionReason      string     `json:"suspension_reason,omitempty"`
+	PasswordResetRequired bool       `json:"password_reset_required"`
+}
+
+func NewPublicUser(user *User) *PublicUser {
+	if user == nil {
+		return nil
+	}
+
+	return &PublicUser{
+		ID:       user.ID,
+		Username: user.Username,
+	}
+}
+
+// the roles must be loaded along with the user
+func NewSelfUser(user *User) SelfUser {
+	roles := user.RoleNames()
+	if roles == nil {
+		roles = []string{}
+	}
+
+	return SelfUser{
+		ID:              user.ID,
+		Username:        user.Username,
+		Email:           user.Email,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		TotpEnabledAt:   user.TotpEnabledAt,
+		Roles:           roles,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+	}
+}
+
+// the roles must be loaded along with their permissions
+func NewAdminUser(user *User) AdminUser {
+	permissions := user.PermissionNames()
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	return AdminUser{
+		SelfUser:              NewSelfUser(user),
+		Permissions:           permissions,
+		SuspendedAt:           user.SuspendedAt,
+		SuspensionReason:      user.SuspensionReason,
+		PasswordResetRequired: user.PasswordResetRequired,
+	}
+}
+
+func NewAdminUsers(users []User) []AdminUser {
+	adminUsers := []AdminUser{}
+	for i := range users {
+		adminUsers = append(adminUsers, NewAdminUser(&users[i]))
+	}
+
+	return adminUsers
+}
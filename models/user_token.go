@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+const (
+	UserTokenPurposeEmailVerification = "email_verification"
+	UserTokenPurposePasswordReset     = "password_reset"
+)
+
+// a single-use token that is sent to the user (e.g. by email) to prove that the
+// user has access to something, only the hash of the token is stored
+type UserToken struct {
+	ID        xid.ID     `gorm:"<-:create;primarykey;not null" json:"id"`
+	Purpose   string     `gorm:"not null;index" json:"purpose"`
+	TokenHash string     `gorm:"not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	UserID xid.ID `gorm:"not null;index" json:"-"`
+	User   *User  `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (ut *UserToken) BeforeCreate(tx *gorm.DB) error {
+	ut.ID = xid.New()
+	return nil
+}
@@ -0,0 +1,14 @@
+package models
+
+type UserTokenDto struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ForgotPasswordDto struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordDto struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8,max=72"`
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// this is the join table of the many-to-many relationship between users and
+// their wishlisted products, besides the references it also keeps a snapshot of
+// the product's price, discount and stock at the moment it was wishlisted so
+// the wishlist can tell the user what has changed since then... the item outlives
+// its product, the product id is nulled when the product is deleted
+type WishlistProduct struct {
+	UserID    xid.ID    `gorm:"not null;uniqueIndex:idx_user_wishlist_products_user_product" json:"-"`
+	ProductID xid.ID    `gorm:"uniqueIndex:idx_user_wishlist_products_user_product" json:"product_id"`
+	Name      string    `gorm:"not null;default:''" json:"name"`
+	Price     uint32    `gorm:"not null;default:0" json:"price"`
+	Discount  uint8     `gorm:"not null;default:0" json:"discount"`
+	Quantity  uint32    `gorm:"not null;default:0" json:"quantity"`
+	CreatedAt time.Time `json:"wishlisted_at"`
+
+	User    *User    `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	Product *Product `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"-"`
+}
+
+func (WishlistProduct) TableName() string {
+	return "user_wishlist_products"
+}
+
+// describes what has changed on the product since it was wishlisted, the product
+// must be loaded along with the wishlist item unless it has been deleted
+func (wp *WishlistProduct) Changes() []string {
+	if wp.Product == nil && !wp.ProductID.IsNil() {
+		return nil
+	}
+
+	snapshot := Product{
+		Name:     wp.Name,
+		Price:    wp.Price,
+		Discount: wp.Discount,
+		Quantity: wp.Quantity,
+	}
+
+	return ProductChanges(&snapshot, wp.Product)
+}
+
+// compares two states of the same product and describes the changes that might
+// interest the users who wishlisted it, e.g. "price dropped 15%" or "back in stock"...
+// a nil after means the product has been deleted
+func ProductChanges(before, after *Product) []string {
+	if after == nil {
+		return []string{"no longer available"}
+	}
+
+	var changes []string
+
+	switch {
+	case before.Quantity == 0 && after.Quantity > 0:
+		changes = append(changes, "back in stock")
+	case before.Quantity > 0 && after.Quantity == 0:
+		changes = append(changes, "out of stock")
+	}
+
+	// the prices are compared after their discounts are applied since that is what the user pays
+	oldPrice, newPrice := before.DiscountedPrice(), after.DiscountedPrice()
+	switch {
+	case oldPrice == 0 || oldPrice == newPrice:
+	case newPrice < oldPrice:
+		changes = append(changes, fmt.Sprintf("price dropped %d%%", percentChange(oldPrice, newPrice)))
+	default:
+		changes = append(changes, fmt.Sprintf("price increased %d%%", percentChange(oldPrice, newPrice)))
+	}
+
+	return changes
+}
+
+func percentChange(oldPrice, newPrice uint32) int {
+	diff := math.Abs(float64(newPrice) - float64(oldPrice))
+	return int(math.Round(diff * 100 / float64(oldPrice)))
+}
+
+// a wishlist item as it is shown to the user, the product in its current state
+// along with the snapshot taken when it was wishlisted and the changes in between
+type WishlistItem struct {
+	Product      *ProductSummary `json:"product"`
+	WishlistedAt time.Time       `json:"wishlisted_at"`
+	Snapshot     Snapshot        `json:"snapshot"`
+	Changes      []string        `json:"changes"`
+}
+
+type Snapshot struct {
+	Name     string `json:"name"`
+	Price    uint32 `json:"price"`
+	Discount uint8  `json:"discount"`
+	Quantity uint32 `json:"quantity"`
+}
+
+func NewWishlistItem(wp *WishlistProduct) WishlistItem {
+	changes := wp.Changes()
+	if changes == nil {
+		changes = []string{}
+	}
+
+	var product *ProductSummary
+	if wp.Product != nil {
+		summary := NewProductSummary(wp.Product)
+		product = &summary
+	}
+
+	return WishlistItem{
+		Product:      product,
+		WishlistedAt: wp.CreatedAt,
+		Snapshot: Snapshot{
+			Name:     wp.Name,
+			Price:    wp.Price,
+			Discount: wp.Discount,
+			Quantity: wp.Quantity,
+		},
+		Changes: changes,
+	}
+}
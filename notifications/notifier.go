@@ -0,0 +1,35 @@
+package notifications
+
+import (
+	"log"
+	"os"
+
+	"github.com/rs/xid"
+)
+
+// a notifier delivers short messages to users, there is only a log-based
+// implementation for now which is good enough for local development, other
+// implementations (email, push notifications, etc.) only need to satisfy this
+// interface and be selected in NewNotifier
+type Notifier interface {
+	Notify(userId xid.ID, subject, message string) error
+}
+
+func NewNotifier() Notifier {
+	return NewLogNotifier()
+}
+
+type logNotifier struct {
+	logger *log.Logger
+}
+
+func NewLogNotifier() Notifier {
+	return &logNotifier{
+		log.New(os.Stdout, "[notification] ", log.LstdFlags),
+	}
+}
+
+func (ln *logNotifier) Notify(userId xid.ID, subject, message string) error {
+	ln.logger.Printf("to=%s subject=%q message=%q\n", userId, subject, message)
+	return nil
+}
@@ -0,0 +1,99 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+const FakeProviderName = "fake"
+
+const (
+	FakeBehaviorSucceed = "succeed"
+	FakeBehaviorDecline = "decline"
+	FakeBehaviorTimeout = "timeout"
+)
+
+// the fake provider runs in-process and never moves any real money, it can be
+// configured to always succeed, always decline, or never respond (so the
+// caller's context times out) which makes it possible to develop and test the
+// checkout flow offline
+type fakeProvider struct {
+	behavior string
+}
+
+func NewFakeProvider(behavior string) (PaymentProvider, error) {
+	switch behavior {
+	case "":
+		behavior = FakeBehaviorSucceed
+	case FakeBehaviorSucceed, FakeBehaviorDecline, FakeBehaviorTimeout:
+	default:
+		return nil, fmt.Errorf("unknown fake payment behavior %q", behavior)
+	}
+
+	return &fakeProvider{behavior}, nil
+}
+
+func (fp *fakeProvider) Name() string {
+	return FakeProviderName
+}
+
+func (fp *fakeProvider) Authorize(ctx context.Context, req AuthorizeRequest) (string, error) {
+	if err := fp.respond(ctx); err != nil {
+		return "", err
+	}
+
+	return "fake_" + xid.New().String(), nil
+}
+
+func (fp *fakeProvider) Capture(ctx context.Context, reference string, amount uint64) error {
+	return fp.respond(ctx)
+}
+
+// voiding always succeeds since there is nothing to decline, the held amount is
+// simply released
+func (fp *fakeProvider) Void(ctx context.Context, reference string) error {
+	return ctx.Err()
+}
+
+func (fp *fakeProvider) Refund(ctx context.Context, reference string, amount uint64) error {
+	return fp.respond(ctx)
+}
+
+// the webhooks of the fake provider are signed with the PAYMENT_WEBHOOK_SECRET
+// env var, see VerifySignature for the details
+func (fp *fakeProvider) VerifyWebhook(header http.Header, body []byte) (*WebhookEvent, error) {
+	timestamp, err := VerifySignature(webhookSecret(), header, body, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+
+	event.Timestamp = timestamp
+	return &event, nil
+}
+
+func (fp *fakeProvider) respond(ctx context.Context) error {
+	switch fp.behavior {
+	case FakeBehaviorDecline:
+		return ErrDeclined
+	case FakeBehaviorTimeout:
+		<-ctx.Done()
+		return ErrTimeout
+	}
+
+	return nil
+}
+
+func webhookSecret() []byte {
+	return []byte(os.Getenv("PAYMENT_WEBHOOK_SECRET"))
+}
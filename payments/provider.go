@@ -0,0 +1,67 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+var (
+	ErrDeclined         = errors.New("payment declined")
+	ErrTimeout          = errors.New("payment provider timed out")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrStaleWebhook     = errors.New("webhook timestamp is outside of the allowed window")
+)
+
+const (
+	EventPaymentAuthorized = "payment.authorized"
+	EventPaymentCaptured   = "payment.captured"
+	EventPaymentRefunded   = "payment.refunded"
+	EventPaymentFailed     = "payment.failed"
+)
+
+// a payment provider is the gateway that actually moves the money, every
+// method that talks to the provider takes a context so the caller can decide
+// how long it is willing to wait for the provider to respond
+type PaymentProvider interface {
+	Name() string
+	// authorizes (holds) the amount and returns the provider's reference of the
+	// payment which is needed to capture or refund it later on
+	Authorize(ctx context.Context, req AuthorizeRequest) (string, error)
+	Capture(ctx context.Context, reference string, amount uint64) error
+	// releases the amount held by an authorization that won't be captured
+	Void(ctx context.Context, reference string) error
+	Refund(ctx context.Context, reference string, amount uint64) error
+	// checks that a webhook request really comes from the provider and parses its event
+	VerifyWebhook(header http.Header, body []byte) (*WebhookEvent, error)
+}
+
+type AuthorizeRequest struct {
+	OrderID xid.ID
+	Amount  uint64
+}
+
+// an event sent by the provider to tell us about a change of a payment's status
+type WebhookEvent struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Reference string    `json:"reference"`
+	Reason    string    `json:"reason,omitempty"`
+	Timestamp time.Time `json:"-"`
+}
+
+// the provider is selected with the PAYMENT_PROVIDER env var, the fake provider
+// is used when it is empty so the app can be run without any real gateway
+func NewProvider(name string) (PaymentProvider, error) {
+	switch name {
+	case "", FakeProviderName:
+		return NewFakeProvider(os.Getenv("FAKE_PAYMENT_BEHAVIOR"))
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", name)
+	}
+}
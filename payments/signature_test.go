@@ -0,0 +1,118 @@
+package payments
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	secret := []byte("webhook-secret")
+	body := []byte(`{"id":"evt_1","type":"payment.captured","reference":"fake_1"}`)
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name    string
+		secret  []byte
+		header  func() http.Header
+		body    []byte
+		wantErr error
+	}{
+		{
+			name:   "valid",
+			secret: secret,
+			header: func() http.Header { return SignWebhook(secret, body, now) },
+			body:   body,
+		},
+		{
+			name:   "within the tolerance",
+			secret: secret,
+			header: func() http.Header { return SignWebhook(secret, body, now.Add(-WebhookTolerance+time.Second)) },
+			body:   body,
+		},
+		{
+			name:    "wrong secret",
+			secret:  secret,
+			header:  func() http.Header { return SignWebhook([]byte("other-secret"), body, now) },
+			body:    body,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "empty secret",
+			secret:  nil,
+			header:  func() http.Header { return SignWebhook(nil, body, now) },
+			body:    body,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "tampered body",
+			secret:  secret,
+			header:  func() http.Header { return SignWebhook(secret, body, now) },
+			body:    []byte(`{"id":"evt_1","type":"payment.refunded","reference":"fake_1"}`),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			// moving the timestamp to get an old event through breaks the signature
+			name:   "tampered timestamp",
+			secret: secret,
+			header: func() http.Header {
+				header := SignWebhook(secret, body, now.Add(-time.Hour))
+				header.Set(TimestampHeader, "1700000000")
+				return header
+			},
+			body:    body,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:   "missing timestamp",
+			secret: secret,
+			header: func() http.Header {
+				header := SignWebhook(secret, body, now)
+				header.Del(TimestampHeader)
+				return header
+			},
+			body:    body,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:   "malformed signature",
+			secret: secret,
+			header: func() http.Header {
+				header := SignWebhook(secret, body, now)
+				header.Set(SignatureHeader, "not-hex")
+				return header
+			},
+			body:    body,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "too old",
+			secret:  secret,
+			header:  func() http.Header { return SignWebhook(secret, body, now.Add(-WebhookTolerance-time.Second)) },
+			body:    body,
+			wantErr: ErrStaleWebhook,
+		},
+		{
+			name:    "from the future",
+			secret:  secret,
+			header:  func() http.Header { return SignWebhook(secret, body, now.Add(WebhookTolerance+time.Second)) },
+			body:    body,
+			wantErr: ErrStaleWebhook,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := tt.header()
+			timestamp, err := VerifySignature(tt.secret, header, tt.body, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && strconv.FormatInt(timestamp.Unix(), 10) != header.Get(TimestampHeader) {
+				t.Fatalf("timestamp = %v, want the one in the header", timestamp)
+			}
+		})
+	}
+}
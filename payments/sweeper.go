@@ -0,0 +1,114 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/laluardian/gin-ecommerce-api/repositories"
+	"gorm.io/gorm"
+)
+
+const (
+	// a payment is only ever pending or authorized for as long as the request
+	// that pays the order, one that has been so for longer than this was left
+	// behind by a request that never finished (a crash, a failed database write...)
+	stalePaymentAge     = 15 * time.Minute
+	sweeperBatchSize    = 100
+	sweeperCallTimeout  = 10 * time.Second
+	stalePaymentFailure = "The payment was not completed in time"
+)
+
+// the stale payments would otherwise keep their orders from ever being paid,
+// since an order can only have one active payment at a time
+type Sweeper interface {
+	FailStale() (int, error)
+	RunSweeper(ctx context.Context, interval time.Duration)
+}
+
+type sweeper struct {
+	repo     repositories.PaymentRepository
+	provider PaymentProvider
+}
+
+func NewSweeper(db *gorm.DB, provider PaymentProvider) Sweeper {
+	return &sweeper{repositories.NewPaymentRepository(db), provider}
+}
+
+// releases whatever the stale payments still hold at the provider and marks them
+// as failed, a payment that can't be released is left as it is and tried again
+// on the next sweep
+func (s *sweeper) FailStale() (int, error) {
+	stale, err := s.repo.FindStale(time.Now().Add(-stalePaymentAge), sweeperBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	failed := 0
+	for i := range stale {
+		payment := &stale[i]
+		if payment.Status == models.PaymentStatusAuthorized {
+			if err := s.release(payment); err != nil {
+				log.Println("Error releasing stale payment", payment.ID, ":", err)
+				continue
+			}
+		}
+
+		from := payment.Status
+		payment.Status = models.PaymentStatusFailed
+		payment.FailureReason = stalePaymentFailure
+		if err := s.repo.Transition(payment, from); err != nil {
+			// it has been finished by its request or a webhook in the meantime
+			if !errors.Is(err, repositories.ErrPaymentChanged) {
+				log.Println("Error failing stale payment", payment.ID, ":", err)
+			}
+			continue
+		}
+		failed++
+	}
+
+	return failed, nil
+}
+
+// the authorization is voided, unless the amount has already been captured (the
+// request died after the capture) in which case it is refunded
+func (s *sweeper) release(payment *models.Payment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sweeperCallTimeout)
+	defer cancel()
+
+	voidErr := s.provider.Void(ctx, payment.Reference)
+	if voidErr == nil {
+		return nil
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), sweeperCallTimeout)
+	defer cancel()
+
+	if err := s.provider.Refund(ctx, payment.Reference, payment.Amount); err != nil {
+		return fmt.Errorf("void: %v, refund: %w", voidErr, err)
+	}
+	return nil
+}
+
+func (s *sweeper) RunSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			failed, err := s.FailStale()
+			if err != nil {
+				log.Println("Error failing stale payments:", err)
+			}
+			if failed > 0 {
+				log.Printf("Failed %d stale payment(s)\n", failed)
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// how a key is locked out: once it has failed Threshold times in a row every
+// further failure locks it for an exponentially growing delay (BaseDelay, twice
+// BaseDelay, four times, ...) up to MaxDelay
+type Policy struct {
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// the failures are forgotten once the longest lockout has passed since the last one
+func (p Policy) window() time.Duration {
+	return p.MaxDelay
+}
+
+// how long the key is locked out for after its last failure
+func (p Policy) delay(failures int) time.Duration {
+	if failures < p.Threshold {
+		return 0
+	}
+
+	exponent := failures - p.Threshold
+	if exponent > 30 {
+		return p.MaxDelay
+	}
+
+	delay := time.Duration(float64(p.BaseDelay) * math.Pow(2, float64(exponent)))
+	if delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+// the account policy can be configured with the LOGIN_MAX_ATTEMPTS (e.g. 5),
+// LOGIN_LOCKOUT_BASE (e.g. "1m") and LOGIN_LOCKOUT_MAX (e.g. "1h") env vars,
+// the ip addresses are given more attempts (LOGIN_IP_MAX_ATTEMPTS) since many
+// users might share one
+func AccountPolicyFromEnv() Policy {
+	return Policy{
+		Threshold: intFromEnv("LOGIN_MAX_ATTEMPTS", 5),
+		BaseDelay: durationFromEnv("LOGIN_LOCKOUT_BASE", time.Minute),
+		MaxDelay:  durationFromEnv("LOGIN_LOCKOUT_MAX", time.Hour),
+	}
+}
+
+func IpPolicyFromEnv() Policy {
+	policy := AccountPolicyFromEnv()
+	policy.Threshold = intFromEnv("LOGIN_IP_MAX_ATTEMPTS", 20)
+	return policy
+}
+
+// the login limiter keeps track of the failed sign in attempts per account and
+// per client ip address, either of them can lock the sign in out... every attempt
+// is counted as a failure before the password is even compared, otherwise the
+// concurrent attempts would all get through before any of them is counted
+type LoginLimiter interface {
+	// returns how long the client has to wait before trying again, zero means
+	// the client can try right away
+	Check(email, ip string) (time.Duration, error)
+	// counts the attempt up front, the client has to wait (and the attempt must
+	// not be made) when the failures before it are already too many
+	Attempt(email, ip string) (time.Duration, error)
+	// undoes the attempt without forgetting the failures before it
+	Undo(email, ip string) error
+	Succeed(email, ip string) error
+	RunSweeper(ctx context.Context, interval time.Duration)
+}
+
+type loginLimiter struct {
+	store         AttemptStore
+	accountPolicy Policy
+	ipPolicy      Policy
+}
+
+func NewLoginLimiter(store AttemptStore, accountPolicy, ipPolicy Policy) LoginLimiter {
+	return &loginLimiter{store, accountPolicy, ipPolicy}
+}
+
+func accountKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func (ll *loginLimiter) Check(email, ip string) (time.Duration, error) {
+	accountAttempts, err := ll.store.Get(accountKey(email))
+	if err != nil {
+		return 0, err
+	}
+
+	ipAttempts, err := ll.store.Get(ipKey(ip))
+	if err != nil {
+		return 0, err
+	}
+
+	return maxDuration(
+		retryAfter(accountAttempts, ll.accountPolicy),
+		retryAfter(ipAttempts, ll.ipPolicy),
+	), nil
+}
+
+// the lockout is worked out from the failures before this attempt, so out of
+// the concurrent attempts only the ones the policy still allows get through
+func (ll *loginLimiter) Attempt(email, ip string) (time.Duration, error) {
+	accountAttempts, err := ll.store.Increment(accountKey(email), ll.accountPolicy.window())
+	if err != nil {
+		return 0, err
+	}
+
+	ipAttempts, err := ll.store.Increment(ipKey(ip), ll.ipPolicy.window())
+	if err != nil {
+		return 0, err
+	}
+
+	return maxDuration(
+		retryAfter(accountAttempts.previous(), ll.accountPolicy),
+		retryAfter(ipAttempts.previous(), ll.ipPolicy),
+	), nil
+}
+
+func (ll *loginLimiter) Undo(email, ip string) error {
+	if err := ll.store.Decrement(accountKey(email)); err != nil {
+		return err
+	}
+
+	return ll.store.Decrement(ipKey(ip))
+}
+
+// the account's counter starts over while the ip address only gets this attempt
+// back, otherwise signing in to an account of their own every now and then would
+// let anyone guess the others' passwords from the same ip address forever
+func (ll *loginLimiter) Succeed(email, ip string) error {
+	if err := ll.store.Reset(accountKey(email)); err != nil {
+		return err
+	}
+
+	return ll.store.Decrement(ipKey(ip))
+}
+
+// the keys that haven't failed for longer than the longest window are deleted
+// every interval
+func (ll *loginLimiter) RunSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	window := maxDuration(ll.accountPolicy.window(), ll.ipPolicy.window())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := ll.store.DeleteExpired(window)
+			if err != nil {
+				log.Println("Error deleting expired sign in attempts:", err)
+			}
+			if deleted > 0 {
+				log.Printf("Deleted %d expired sign in attempt(s)\n", deleted)
+			}
+		}
+	}
+}
+
+func retryAfter(attempts Attempts, policy Policy) time.Duration {
+	lockedUntil := attempts.LastFailureAt.Add(policy.delay(attempts.Failures))
+	if wait := time.Until(lockedUntil); wait > 0 {
+		return wait
+	}
+
+	return 0
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func intFromEnv(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+
+	return value
+}
+
+func durationFromEnv(key string, defaultValue time.Duration) time.Duration {
+	duration, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || duration <= 0 {
+		return defaultValue
+	}
+
+	return duration
+}
@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+type memoryStore struct {
+	mu       sync.Mutex
+	attempts map[string]Attempts
+}
+
+func NewMemoryStore() AttemptStore {
+	return &memoryStore{attempts: make(map[string]Attempts)}
+}
+
+func (ms *memoryStore) Get(key string) (Attempts, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return ms.attempts[key], nil
+}
+
+func (ms *memoryStore) Increment(key string, window time.Duration) (Attempts, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := time.Now()
+	attempts := ms.attempts[key]
+	if now.Sub(attempts.LastFailureAt) > window {
+		attempts.Failures = 0
+	}
+	attempts.Failures++
+	attempts.PreviousFailureAt = attempts.LastFailureAt
+	attempts.LastFailureAt = now
+	ms.attempts[key] = attempts
+
+	return attempts, nil
+}
+
+func (ms *memoryStore) Decrement(key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	attempts, ok := ms.attempts[key]
+	if !ok {
+		return nil
+	}
+
+	if attempts.Failures <= 1 {
+		delete(ms.attempts, key)
+		return nil
+	}
+
+	ms.attempts[key] = attempts.previous()
+	return nil
+}
+
+func (ms *memoryStore) Reset(key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	delete(ms.attempts, key)
+	return nil
+}
+
+// the keys that haven't failed for a while are dropped every now and then by the
+// sweeper so the map doesn't keep growing
+func (ms *memoryStore) DeleteExpired(window time.Duration) (int64, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	var deleted int64
+	now := time.Now()
+	for key, attempts := range ms.attempts {
+		if now.Sub(attempts.LastFailureAt) > window {
+			delete(ms.attempts, key)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
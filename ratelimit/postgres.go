@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"errors"
+	"time"
+
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type postgresStore struct {
+	db *gorm.DB
+}
+
+func NewPostgresStore(db *gorm.DB) AttemptStore {
+	return &postgresStore{db}
+}
+
+func (ps *postgresStore) Get(key string) (Attempts, error) {
+	var attempt models.LoginAttempt
+	err := ps.db.First(&attempt, "key = ?", key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Attempts{}, nil
+	}
+
+	return toAttempts(&attempt), err
+}
+
+// the counter is incremented with a single upsert so the concurrent failures
+// (possibly on different instances of the api) are all counted
+func (ps *postgresStore) Increment(key string, window time.Duration) (Attempts, error) {
+	now := time.Now()
+	attempt := models.LoginAttempt{Key: key, Failures: 1, LastFailureAt: now}
+
+	err := ps.db.
+		Clauses(
+			clause.OnConflict{
+				Columns: []clause.Column{{Name: "key"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"failures": gorm.Expr("CASE WHEN login_attempts.last_failure_at < ? THEN 1 "+
+						"ELSE login_attempts.failures + 1 END", now.Add(-window)),
+					"last_failure_at":     now,
+					"previous_failure_at": gorm.Expr("login_attempts.last_failure_at"),
+				}),
+			},
+			clause.Returning{},
+		).
+		Create(&attempt).Error
+
+	return toAttempts(&attempt), err
+}
+
+// the row is left in place even when there are no failures left, the sweeper
+// deletes it along with the rest
+func (ps *postgresStore) Decrement(key string) error {
+	return ps.db.
+		Model(&models.LoginAttempt{}).
+		Where("key = ? AND failures > 0", key).
+		Updates(map[string]interface{}{
+			"failures":            gorm.Expr("failures - 1"),
+			"last_failure_at":     gorm.Expr("COALESCE(previous_failure_at, last_failure_at)"),
+			"previous_failure_at": nil,
+		}).Error
+}
+
+func (ps *postgresStore) Reset(key string) error {
+	return ps.db.Delete(&models.LoginAttempt{}, "key = ?", key).Error
+}
+
+func (ps *postgresStore) DeleteExpired(window time.Duration) (int64, error) {
+	result := ps.db.Delete(&models.LoginAttempt{}, "last_failure_at < ?", time.Now().Add(-window))
+	return result.RowsAffected, result.Error
+}
+
+func toAttempts(attempt *models.LoginAttempt) Attempts {
+	attempts := Attempts{Failures: attempt.Failures, LastFailureAt: attempt.LastFailureAt}
+	if attempt.PreviousFailureAt != nil {
+		attempts.PreviousFailureAt = *attempt.PreviousFailureAt
+	}
+
+	return attempts
+}
@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	MemoryStoreName   = "memory"
+	PostgresStoreName = "postgres"
+)
+
+// the failed attempts of a key, the zero value means there are none... the
+// attempt that is being made right now is counted as a failure too until it
+// succeeds
+type Attempts struct {
+	Failures      int
+	LastFailureAt time.Time
+	// when the failure before the last one happened, so the last one can be undone
+	PreviousFailureAt time.Time
+}
+
+// the attempts as they were before the last failure
+func (a Attempts) previous() Attempts {
+	if a.Failures <= 1 {
+		return Attempts{}
+	}
+
+	return Attempts{Failures: a.Failures - 1, LastFailureAt: a.PreviousFailureAt}
+}
+
+// an attempt store keeps the failure counters, the memory one is only good for a
+// single instance of the api while the postgres one is shared by all of them
+type AttemptStore interface {
+	Get(key string) (Attempts, error)
+	// counts one more failure, the counter starts over when the last failure is
+	// older than the window
+	Increment(key string, window time.Duration) (Attempts, error)
+	// undoes the last increment, the last failure goes back to the previous one
+	Decrement(key string) error
+	Reset(key string) error
+	// deletes the keys whose last failure is older than the window
+	DeleteExpired(window time.Duration) (int64, error)
+}
+
+// the store is selected with the LOGIN_ATTEMPT_STORE env var, the postgres store
+// is used when it is empty
+func NewAttemptStore(name string, db *gorm.DB) (AttemptStore, error) {
+	switch name {
+	case "", PostgresStoreName:
+		return NewPostgresStore(db), nil
+	case MemoryStoreName:
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown login attempt store %q", name)
+	}
+}
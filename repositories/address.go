@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"github.com/laluardian/gin-ecommerce-api/libs"
 	"github.com/laluardian/gin-ecommerce-api/models"
 	"github.com/rs/xid"
 	"gorm.io/gorm"
@@ -8,7 +9,7 @@ import (
 
 type AddressRepository interface {
 	Create(address *models.Address) error
-	FindByUser(userId xid.ID) ([]models.Address, error)
+	FindByUser(userId xid.ID, p *libs.Pagination) ([]models.Address, error)
 	FindByIds(userId, addressId xid.ID) (models.Address, error)
 	Update(address *models.Address) error
 	Delete(addressId xid.ID) error
@@ -26,8 +27,15 @@ func (ar *addressRepository) Create(address *models.Address) error {
 	return ar.db.Create(&address).Error
 }
 
-func (ar *addressRepository) FindByUser(userId xid.ID) (addresses []models.Address, err error) {
-	err = ar.db.Find(&addresses, "user_id = ?", userId).Error
+func (ar *addressRepository) FindByUser(userId xid.ID, p *libs.Pagination) (addresses []models.Address, err error) {
+	if err = ar.db.Model(&models.Address{}).Where("user_id = ?", userId).Count(&p.Total).Error; err != nil {
+		return addresses, err
+	}
+
+	err = ar.db.Scopes(p.Paginate("id")).Find(&addresses, "user_id = ?", userId).Error
+	if len(addresses) > 0 {
+		p.SetNextCursor(len(addresses), addresses[len(addresses)-1].ID)
+	}
 	return addresses, err
 }
 
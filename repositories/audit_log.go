@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"github.com/laluardian/gin-ecommerce-api/libs"
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+type AuditLogRepository interface {
+	Create(auditLog *models.AuditLog) error
+	FindMany(targetId xid.ID, p *libs.Pagination) ([]models.AuditLog, error)
+}
+
+type auditLogRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditLogRepository(db *gorm.DB) AuditLogRepository {
+	return &auditLogRepository{db}
+}
+
+func (alr *auditLogRepository) Create(auditLog *models.AuditLog) error {
+	return alr.db.Create(&auditLog).Error
+}
+
+// the records of every target are returned when the target id is nil
+func (alr *auditLogRepository) FindMany(targetId xid.ID, p *libs.Pagination) (auditLogs []models.AuditLog, err error) {
+	db := alr.db
+	if !targetId.IsNil() {
+		db = db.Where("target_id = ?", targetId)
+	}
+
+	if err = db.Model(&models.AuditLog{}).Count(&p.Total).Error; err != nil {
+		return auditLogs, err
+	}
+
+	err = db.Scopes(p.Paginate("id")).Find(&auditLogs).Error
+	if len(auditLogs) > 0 {
+		p.SetNextCursor(len(auditLogs), auditLogs[len(auditLogs)-1].ID)
+	}
+	return auditLogs, err
+}
@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CartRepository interface {
+	FindOrCreateByUser(userId xid.ID) (models.Cart, error)
+	FindItem(cartId, productId xid.ID) (models.CartItem, error)
+	SaveItem(item *models.CartItem) error
+	AddItem(item *models.CartItem, limit uint32) (bool, error)
+	RemoveItem(cartId, productId xid.ID) error
+	Clear(cartId xid.ID) error
+}
+
+type cartRepository struct {
+	db *gorm.DB
+}
+
+func NewCartRepository(db *gorm.DB) CartRepository {
+	return &cartRepository{db}
+}
+
+// the cart is created the first time it is needed, two requests that both need
+// it at the same time both end up with the one that was created first
+func (cr *cartRepository) FindOrCreateByUser(userId xid.ID) (cart models.Cart, err error) {
+	cart, err = cr.findByUser(userId)
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return cart, err
+	}
+
+	err = cr.db.
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "user_id"}}, DoNothing: true}).
+		Create(&models.Cart{UserID: userId}).Error
+	if err != nil {
+		return cart, err
+	}
+
+	return cr.findByUser(userId)
+}
+
+func (cr *cartRepository) findByUser(userId xid.ID) (cart models.Cart, err error) {
+	err = cr.db.
+		Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at")
+		}).
+		Preload("Items.Product").
+		First(&cart, "user_id = ?", userId).Error
+	return cart, err
+}
+
+func (cr *cartRepository) FindItem(cartId, productId xid.ID) (item models.CartItem, err error) {
+	err = cr.db.First(&item, "cart_id = ? AND product_id = ?", cartId, productId).Error
+	return item, err
+}
+
+// inserts the item or, if the product is already in the cart, overwrites its quantity
+func (cr *cartRepository) SaveItem(item *models.CartItem) error {
+	return cr.db.
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "cart_id"}, {Name: "product_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"quantity", "updated_at"}),
+		}).
+		Create(&item).Error
+}
+
+// inserts the item or, if the product is already in the cart, adds to its quantity
+// in the same statement so concurrent additions can't overwrite each other... the
+// quantity is left as it is when the total would go over the limit, in which
+// case false is returned
+func (cr *cartRepository) AddItem(item *models.CartItem, limit uint32) (bool, error) {
+	result := cr.db.
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "cart_id"}, {Name: "product_id"}},
+			DoUpdates: clause.Set{
+				{Column: clause.Column{Name: "quantity"}, Value: gorm.Expr(`"cart_items"."quantity" + "excluded"."quantity"`)},
+				{Column: clause.Column{Name: "updated_at"}, Value: gorm.Expr(`"excluded"."updated_at"`)},
+			},
+			Where: clause.Where{Exprs: []clause.Expression{
+				gorm.Expr(`"cart_items"."quantity" + "excluded"."quantity" <= ?`, limit),
+			}},
+		}).
+		Create(&item)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func (cr *cartRepository) RemoveItem(cartId, productId xid.ID) error {
+	var item models.CartItem
+	return cr.db.Delete(&item, "cart_id = ? AND product_id = ?", cartId, productId).Error
+}
+
+func (cr *cartRepository) Clear(cartId xid.ID) error {
+	var item models.CartItem
+	return cr.db.Delete(&item, "cart_id = ?", cartId).Error
+}
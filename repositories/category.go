@@ -1,14 +1,32 @@
 package repositories
 
 import (
+	"strings"
+
+	"github.com/laluardian/gin-ecommerce-api/libs"
 	"github.com/laluardian/gin-ecommerce-api/models"
 	"gorm.io/gorm"
 )
 
+// the fields of a category (see models.CategoryDetail) the clients can ask for
+var CategoryFields = libs.FieldSetSchema{
+	Fields:     []string{"id", "name", "description", "slug", "created_at", "updated_at"},
+	Expansions: []string{"products", "products_count"},
+}
+
+var categoryFieldColumns = map[string][]string{
+	"id":          {"categories.id"},
+	"name":        {"categories.name"},
+	"description": {"categories.description"},
+	"slug":        {"categories.slug"},
+	"created_at":  {"categories.created_at"},
+	"updated_at":  {"categories.updated_at"},
+}
+
 type CategoryRepository interface {
 	Create(category *models.Category) error
-	FindMany() ([]models.Category, error)
-	FindBySlug(slug string) (models.Category, error)
+	FindMany(p *libs.Pagination, fields *libs.FieldSet) ([]models.Category, error)
+	FindBySlug(slug string, fields *libs.FieldSet) (models.Category, error)
 	Update(category *models.Category) error
 	Delete(slug string) error
 }
@@ -26,13 +44,21 @@ func (cr *categoryRepository) Create(category *models.Category) error {
 	return err
 }
 
-func (cr *categoryRepository) FindMany() (categories []models.Category, err error) {
-	err = cr.db.Find(&categories).Error
+func (cr *categoryRepository) FindMany(p *libs.Pagination, fields *libs.FieldSet) (categories []models.Category, err error) {
+	if err = cr.db.Model(&models.Category{}).Count(&p.Total).Error; err != nil {
+		return categories, err
+	}
+
+	err = cr.db.Scopes(selectCategories(fields), p.Paginate("categories.id")).Find(&categories).Error
+	if len(categories) > 0 {
+		p.SetNextCursor(len(categories), categories[len(categories)-1].ID)
+	}
 	return categories, err
 }
 
-func (cr *categoryRepository) FindBySlug(slug string) (category models.Category, err error) {
-	err = cr.db.Preload("Products").First(&category, "slug = ?", slug).Error
+// a nil fieldset finds the category with all of its fields but without its products
+func (cr *categoryRepository) FindBySlug(slug string, fields *libs.FieldSet) (category models.Category, err error) {
+	err = cr.db.Scopes(selectCategories(fields)).First(&category, "categories.slug = ?", slug).Error
 	return category, err
 }
 
@@ -46,3 +72,21 @@ func (cr *categoryRepository) Delete(slug string) error {
 	err := cr.db.Delete(&category, "slug = ?", slug).Error
 	return err
 }
+
+// the products of a category are only loaded (or counted) when they are asked for
+func selectCategories(fields *libs.FieldSet) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		columns := fields.Columns(categoryFieldColumns, CategoryFields.Fields)
+		if fields.Expands("products_count") {
+			columns = append(columns, "(SELECT COUNT(*) FROM product_categories "+
+				"WHERE product_categories.category_id = categories.id) AS products_count")
+		}
+		db = db.Select(strings.Join(columns, ", "))
+
+		if fields.Expands("products") {
+			db = db.Preload("Products")
+		}
+
+		return db
+	}
+}
@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"github.com/laluardian/gin-ecommerce-api/libs"
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+type OrderRepository interface {
+	Create(order *models.Order) error
+	FindMany(p *libs.Pagination) ([]models.Order, error)
+	FindByUser(userId xid.ID, p *libs.Pagination) ([]models.Order, error)
+	FindById(orderId xid.ID) (models.Order, error)
+	FindByIds(userId, orderId xid.ID) (models.Order, error)
+	UpdateStatus(order *models.Order, status string) error
+}
+
+type orderRepository struct {
+	db *gorm.DB
+}
+
+func NewOrderRepository(db *gorm.DB) OrderRepository {
+	return &orderRepository{db}
+}
+
+func (or *orderRepository) Create(order *models.Order) error {
+	return or.db.Create(&order).Error
+}
+
+func (or *orderRepository) FindMany(p *libs.Pagination) (orders []models.Order, err error) {
+	if err = or.db.Model(&models.Order{}).Count(&p.Total).Error; err != nil {
+		return orders, err
+	}
+
+	err = or.db.Scopes(p.Paginate("id")).Preload("Items").Find(&orders).Error
+	if len(orders) > 0 {
+		p.SetNextCursor(len(orders), orders[len(orders)-1].ID)
+	}
+	return orders, err
+}
+
+func (or *orderRepository) FindByUser(userId xid.ID, p *libs.Pagination) (orders []models.Order, err error) {
+	if err = or.db.Model(&models.Order{}).Where("user_id = ?", userId).Count(&p.Total).Error; err != nil {
+		return orders, err
+	}
+
+	err = or.db.Scopes(p.Paginate("id")).Preload("Items").Find(&orders, "user_id = ?", userId).Error
+	if len(orders) > 0 {
+		p.SetNextCursor(len(orders), orders[len(orders)-1].ID)
+	}
+	return orders, err
+}
+
+func (or *orderRepository) FindById(orderId xid.ID) (order models.Order, err error) {
+	err = or.db.Preload("Address").Preload("Items").First(&order, "id = ?", orderId).Error
+	return order, err
+}
+
+func (or *orderRepository) FindByIds(userId, orderId xid.ID) (order models.Order, err error) {
+	err = or.db.Preload("Address").Preload("Items").First(&order, "id = ? AND user_id = ?", orderId, userId).Error
+	return order, err
+}
+
+func (or *orderRepository) UpdateStatus(order *models.Order, status string) error {
+	return or.db.Model(&order).Update("status", status).Error
+}
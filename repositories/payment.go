@@ -0,0 +1,156 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrOrderNotPending   = errors.New("only pending orders can be paid")
+	ErrPaymentInProgress = errors.New("the order already has a payment in progress")
+	ErrPaymentChanged    = errors.New("the payment has been changed by another request")
+)
+
+// the statuses of the payments that are still (or already) moving money, see the
+// idx_payments_order_active index
+var activePaymentStatuses = []string{
+	models.PaymentStatusPending,
+	models.PaymentStatusAuthorized,
+	models.PaymentStatusCaptured,
+	models.PaymentStatusRefunding,
+}
+
+type PaymentRepository interface {
+	Create(payment *models.Payment) error
+	CreateForOrder(payment *models.Payment, userId, orderId xid.ID) (models.Order, error)
+	FindById(paymentId xid.ID) (models.Payment, error)
+	FindByOrder(orderId xid.ID) ([]models.Payment, error)
+	FindByReference(provider, reference string) (models.Payment, error)
+	FindStale(before time.Time, limit int) ([]models.Payment, error)
+	Update(payment *models.Payment) error
+	Transition(payment *models.Payment, from string) error
+	CaptureForOrder(payment *models.Payment, from string) error
+}
+
+type paymentRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentRepository(db *gorm.DB) PaymentRepository {
+	return &paymentRepository{db}
+}
+
+func (pr *paymentRepository) Create(payment *models.Payment) error {
+	return pr.db.Create(&payment).Error
+}
+
+// creates the payment of a pending order, the order row is locked so two payments
+// of the same order can't be started at the same time... the amount is always
+// the total price of the order
+func (pr *paymentRepository) CreateForOrder(payment *models.Payment, userId, orderId xid.ID) (order models.Order, err error) {
+	err = pr.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&order, "id = ? AND user_id = ?", orderId, userId).Error
+		if err != nil {
+			return err
+		}
+
+		if order.Status != models.OrderStatusPending {
+			return ErrOrderNotPending
+		}
+
+		var active int64
+		err = tx.Model(&models.Payment{}).
+			Where("order_id = ? AND status IN ?", order.ID, activePaymentStatuses).
+			Count(&active).Error
+		if err != nil {
+			return err
+		}
+		if active > 0 {
+			return ErrPaymentInProgress
+		}
+
+		payment.OrderID = order.ID
+		payment.Amount = order.TotalPrice
+		return tx.Create(payment).Error
+	})
+
+	return order, err
+}
+
+func (pr *paymentRepository) FindById(paymentId xid.ID) (payment models.Payment, err error) {
+	err = pr.db.First(&payment, "id = ?", paymentId).Error
+	return payment, err
+}
+
+func (pr *paymentRepository) FindByOrder(orderId xid.ID) (payments []models.Payment, err error) {
+	err = pr.db.Order("created_at DESC").Find(&payments, "order_id = ?", orderId).Error
+	return payments, err
+}
+
+func (pr *paymentRepository) FindByReference(provider, reference string) (payment models.Payment, err error) {
+	err = pr.db.First(&payment, "provider = ? AND reference = ?", provider, reference).Error
+	return payment, err
+}
+
+// the payments that have been pending or authorized since before the given time,
+// i.e. the ones whose request never finished them
+func (pr *paymentRepository) FindStale(before time.Time, limit int) (payments []models.Payment, err error) {
+	err = pr.db.
+		Where("status IN ? AND updated_at < ?", []string{models.PaymentStatusPending, models.PaymentStatusAuthorized}, before).
+		Order("updated_at").
+		Limit(limit).
+		Find(&payments).Error
+	return payments, err
+}
+
+// only the fields that change during the lifetime of a payment are updated
+func (pr *paymentRepository) Update(payment *models.Payment) error {
+	return pr.db.Model(&payment).Select("Reference", "Status", "FailureReason").Updates(&payment).Error
+}
+
+// updates the payment only if its status is still the one it is moving from, so
+// when two requests try to move the same payment only the first one succeeds
+func (pr *paymentRepository) Transition(payment *models.Payment, from string) error {
+	result := pr.db.Model(&payment).
+		Where("status = ?", from).
+		Select("Reference", "Status", "FailureReason").
+		Updates(&payment)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPaymentChanged
+	}
+
+	return nil
+}
+
+// moves the payment to captured and its order to paid in the same transaction,
+// so a captured payment never belongs to an order that is still pending... the
+// order must still be pending, otherwise nothing is changed
+func (pr *paymentRepository) CaptureForOrder(payment *models.Payment, from string) error {
+	return pr.db.Transaction(func(tx *gorm.DB) error {
+		if err := NewPaymentRepository(tx).Transition(payment, from); err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.Order{}).
+			Where("id = ? AND status = ?", payment.OrderID, models.OrderStatusPending).
+			Update("status", models.OrderStatusPaid)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrderNotPending
+		}
+
+		return nil
+	})
+}
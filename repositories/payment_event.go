@@ -0,0 +1,51 @@
+package repositories
+
+import (
+	"github.com/laluardian/gin-ecommerce-api/libs"
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PaymentEventRepository interface {
+	Create(event *models.PaymentEvent) (bool, error)
+	FindMany(p *libs.Pagination) ([]models.PaymentEvent, error)
+	FindById(eventId xid.ID) (models.PaymentEvent, error)
+	Update(event *models.PaymentEvent) error
+}
+
+type paymentEventRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentEventRepository(db *gorm.DB) PaymentEventRepository {
+	return &paymentEventRepository{db}
+}
+
+// returns false if the provider has already sent an event with the same id
+func (per *paymentEventRepository) Create(event *models.PaymentEvent) (bool, error) {
+	result := per.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&event)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (per *paymentEventRepository) FindMany(p *libs.Pagination) (events []models.PaymentEvent, err error) {
+	if err = per.db.Model(&models.PaymentEvent{}).Count(&p.Total).Error; err != nil {
+		return events, err
+	}
+
+	err = per.db.Scopes(p.Paginate("id")).Find(&events).Error
+	if len(events) > 0 {
+		p.SetNextCursor(len(events), events[len(events)-1].ID)
+	}
+	return events, err
+}
+
+func (per *paymentEventRepository) FindById(eventId xid.ID) (event models.PaymentEvent, err error) {
+	err = per.db.First(&event, "id = ?", eventId).Error
+	return event, err
+}
+
+func (per *paymentEventRepository) Update(event *models.PaymentEvent) error {
+	return per.db.Model(&event).Select("Status", "Error", "ProcessedAt").Updates(&event).Error
+}
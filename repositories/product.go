@@ -1,20 +1,67 @@
 package repositories
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/laluardian/gin-ecommerce-api/libs"
 	"github.com/laluardian/gin-ecommerce-api/models"
 	"github.com/rs/xid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// the fields of the product list (see models.ProductSummary) and of a single
+// product (see models.ProductDetail) the clients can ask for
+var (
+	ProductSummaryFields = libs.FieldSetSchema{
+		Fields: []string{"id", "name", "price", "discount", "in_stock", "average_rating",
+			"review_count", "snippet"},
+		Expansions: []string{"categories", "wishlisted_by_count"},
+	}
+	ProductDetailFields = libs.FieldSetSchema{
+		Fields: []string{"id", "name", "price", "discount", "in_stock", "average_rating",
+			"review_count", "description", "quantity", "created_at", "updated_at"},
+		Expansions: []string{"categories", "wishlisted_by_count"},
+	}
 )
 
+// the columns each of the fields is made of, the ratings come from the subquery
+// joined by withRatings and the snippet is selected along with the search rank
+var productFieldColumns = map[string][]string{
+	"id":             {"products.id"},
+	"name":           {"products.name"},
+	"price":          {"products.price"},
+	"discount":       {"products.discount"},
+	"in_stock":       {"products.quantity"},
+	"quantity":       {"products.quantity"},
+	"average_rating": {"COALESCE(ratings.average_rating, 0) AS average_rating"},
+	"review_count":   {"COALESCE(ratings.review_count, 0) AS review_count"},
+	"description":    {"products.description"},
+	"created_at":     {"products.created_at"},
+	"updated_at":     {"products.updated_at"},
+}
+
 type ProductRepository interface {
 	Create(product *models.Product) error
-	FindMany(keyword string) ([]models.Product, error)
-	FindById(userId xid.ID) (models.Product, error)
+	FindMany(filter *models.ProductFilter, p *libs.Pagination, fields *libs.FieldSet) ([]models.Product, error)
+	FindFacets(filter *models.ProductFilter) (models.ProductFacets, error)
+	Suggest(query string, filter *models.ProductFilter, limit int) ([]models.ProductSuggestion, error)
+	FindById(productId xid.ID, fields *libs.FieldSet) (models.Product, error)
+	FindWithWishlistedBy(productId xid.ID) (models.Product, error)
+	FindByIds(productIds []xid.ID) ([]models.Product, error)
+	FindByIdsForUpdate(productIds []xid.ID) ([]models.Product, error)
 	Update(product *models.Product) error
 	Delete(productId xid.ID) error
-	AddToWishlist(product *models.Product) error
+	AddToWishlist(product *models.Product, user *models.User) error
 	RemoveFromWishlist(product *models.Product, user *models.User) error
 	ClearCategories(product *models.Product) error
+	IncrementQuantity(productId xid.ID, quantity uint32) error
+	DecrementQuantity(productId xid.ID, quantity uint32) error
 }
 
 type productRepository struct {
@@ -29,16 +76,148 @@ func (pr *productRepository) Create(product *models.Product) error {
 	return pr.db.Omit("Categories.*").Create(&product).Error
 }
 
-func (pr *productRepository) FindMany(keyword string) (products []models.Product, err error) {
-	err = pr.db.Preload("Categories").Find(&products, "LOWER(name) LIKE LOWER(?)", "%"+keyword+"%").Error
+func (pr *productRepository) FindMany(filter *models.ProductFilter, p *libs.Pagination, fields *libs.FieldSet) (products []models.Product, err error) {
+	if err = pr.db.Model(&models.Product{}).Scopes(filterProducts(filter)).Count(&p.Total).Error; err != nil {
+		return products, err
+	}
+
+	// the sort order goes before the pagination's, so the ids only break the ties
+	err = pr.db.
+		Scopes(
+			filterProducts(filter),
+			withRatings,
+			selectProducts(&ProductSummaryFields, fields, filter),
+			withExpansions(fields),
+			sortProducts(filter),
+			p.Paginate("products.id"),
+		).
+		Find(&products).Error
+	if len(products) > 0 {
+		p.SetNextCursor(len(products), products[len(products)-1].ID)
+	}
 	return products, err
 }
 
-func (pr *productRepository) FindById(productId xid.ID) (product models.Product, err error) {
-	err = pr.db.Preload("WishlistedBy").Preload("Categories").First(&product, "id = ?", productId).Error
+// every facet is counted with all of the filters except its own, otherwise
+// e.g. filtering by a category would make all the other categories count zero
+func (pr *productRepository) FindFacets(filter *models.ProductFilter) (facets models.ProductFacets, err error) {
+	categoryFilter := *filter
+	categoryFilter.Categories = nil
+
+	err = pr.db.
+		Model(&models.Product{}).
+		Scopes(filterProducts(&categoryFilter)).
+		Select("categories.slug, categories.name, COUNT(DISTINCT products.id) AS count").
+		Joins("JOIN product_categories ON product_categories.product_id = products.id").
+		Joins("JOIN categories ON categories.id = product_categories.category_id").
+		Group("categories.slug, categories.name").
+		Order("count DESC, categories.name").
+		Scan(&facets.Categories).Error
+	if err != nil {
+		return facets, err
+	}
+
+	priceFilter := *filter
+	priceFilter.MinPrice = nil
+	priceFilter.MaxPrice = nil
+
+	// the bucket of a product is the index of the first bound its price is below
+	bucket := "CASE"
+	for i, bound := range models.PriceBucketBounds {
+		bucket += fmt.Sprintf(" WHEN products.price < %d THEN %d", bound, i)
+	}
+	bucket += fmt.Sprintf(" ELSE %d END", len(models.PriceBucketBounds))
+
+	var bucketCounts []struct {
+		Bucket int
+		Count  int64
+	}
+	err = pr.db.
+		Model(&models.Product{}).
+		Scopes(filterProducts(&priceFilter)).
+		Select(bucket + " AS bucket, COUNT(*) AS count").
+		Group("bucket").
+		Scan(&bucketCounts).Error
+	if err != nil {
+		return facets, err
+	}
+
+	var min uint32
+	for i := range models.PriceBucketBounds {
+		max := models.PriceBucketBounds[i]
+		facets.PriceBuckets = append(facets.PriceBuckets, models.PriceBucket{Min: min, Max: &max})
+		min = max
+	}
+	facets.PriceBuckets = append(facets.PriceBuckets, models.PriceBucket{Min: min})
+	for _, bucketCount := range bucketCounts {
+		facets.PriceBuckets[bucketCount.Bucket].Count = bucketCount.Count
+	}
+
+	stockFilter := *filter
+	stockFilter.InStock = nil
+
+	err = pr.db.
+		Model(&models.Product{}).
+		Scopes(filterProducts(&stockFilter)).
+		Select("COUNT(*) FILTER (WHERE products.quantity > 0) AS in_stock, " +
+			"COUNT(*) FILTER (WHERE products.quantity = 0) AS out_of_stock").
+		Scan(&facets.Stock).Error
+
+	return facets, err
+}
+
+// suggests the names of the products that are the most similar to the query
+// using trigram similarity (pg_trgm), the names that start with the query are
+// suggested too even if they are too short to be similar enough
+func (pr *productRepository) Suggest(query string, filter *models.ProductFilter, limit int) (suggestions []models.ProductSuggestion, err error) {
+	prefix := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query) + "%"
+
+	err = pr.db.
+		Model(&models.Product{}).
+		Scopes(filterProducts(filter)).
+		Select("products.id, products.name, similarity(products.name, ?) AS score", query).
+		Where("products.name % ? OR products.name ILIKE ?", query, prefix).
+		Order("score DESC, products.name").
+		Limit(limit).
+		Scan(&suggestions).Error
+	return suggestions, err
+}
+
+func (pr *productRepository) FindById(productId xid.ID, fields *libs.FieldSet) (product models.Product, err error) {
+	err = pr.db.
+		Scopes(
+			withRatings,
+			selectProducts(&ProductDetailFields, fields, nil),
+			withExpansions(fields),
+		).
+		First(&product, "products.id = ?", productId).Error
+	return product, err
+}
+
+// the users who wishlisted the product are only loaded when they are needed,
+// e.g. to notify them about a change
+func (pr *productRepository) FindWithWishlistedBy(productId xid.ID) (product models.Product, err error) {
+	err = pr.db.Preload("WishlistedBy").First(&product, "id = ?", productId).Error
 	return product, err
 }
 
+func (pr *productRepository) FindByIds(productIds []xid.ID) (products []models.Product, err error) {
+	err = pr.db.Find(&products, "id IN ?", productIds).Error
+	return products, err
+}
+
+// locks the selected product rows (SELECT ... FOR UPDATE) until the end of the
+// transaction, so this is only meaningful when the repository is built on top of
+// a transaction... the rows are locked in the order of their ids to prevent
+// deadlocks between concurrent transactions that lock the same products
+func (pr *productRepository) FindByIdsForUpdate(productIds []xid.ID) (products []models.Product, err error) {
+	err = pr.db.
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Order("id").
+		Find(&products, "id IN ?", productIds).Error
+	return products, err
+}
+
 func (pr *productRepository) Update(product *models.Product) error {
 	return pr.db.
 		Omit("Categories.*").
@@ -51,11 +230,20 @@ func (pr *productRepository) Delete(productId xid.ID) error {
 	return pr.db.Delete(&product, "id = ?", productId).Error
 }
 
-func (pr *productRepository) AddToWishlist(product *models.Product) error {
-	err := pr.db.
-		Omit("WishlistedBy.*").
-		Session(&gorm.Session{FullSaveAssociations: true}).
-		Updates(&product).Error
+// the product's current name, price, discount and stock are saved along with
+// the wishlist record so they can be compared later on (or told apart once the
+// product is gone)
+func (pr *productRepository) AddToWishlist(product *models.Product, user *models.User) error {
+	wishlistProduct := models.WishlistProduct{
+		UserID:    user.ID,
+		ProductID: product.ID,
+		Name:      product.Name,
+		Price:     product.Price,
+		Discount:  product.Discount,
+		Quantity:  product.Quantity,
+	}
+
+	err := pr.db.Create(&wishlistProduct).Error
 	return err
 }
 
@@ -68,3 +256,83 @@ func (pr *productRepository) ClearCategories(product *models.Product) error {
 	err := pr.db.Model(&product).Association("Categories").Clear()
 	return err
 }
+
+func (pr *productRepository) IncrementQuantity(productId xid.ID, quantity uint32) error {
+	return pr.db.
+		Model(&models.Product{}).
+		Where("id = ?", productId).
+		UpdateColumn("quantity", gorm.Expr("quantity + ?", quantity)).Error
+}
+
+// the quantity of a product is never allowed to go below zero, if there is not
+// enough stock left no row is updated and ErrInsufficientStock is returned
+func (pr *productRepository) DecrementQuantity(productId xid.ID, quantity uint32) error {
+	result := pr.db.
+		Model(&models.Product{}).
+		Where("id = ? AND quantity >= ?", productId, quantity).
+		UpdateColumn("quantity", gorm.Expr("quantity - ?", quantity))
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrInsufficientStock
+	}
+
+	return nil
+}
+
+// the ratings of the products are aggregated in a single subquery that is joined
+// to the products, so listing many products along with their average rating
+// and review count doesn't need a query per product (they are selected by
+// selectProducts when they are asked for)
+func withRatings(db *gorm.DB) *gorm.DB {
+	return db.
+		Joins("LEFT JOIN (" +
+			"SELECT product_id, AVG(rating) AS average_rating, COUNT(*) AS review_count " +
+			"FROM reviews WHERE is_hidden = false GROUP BY product_id" +
+			") AS ratings ON ratings.product_id = products.id")
+}
+
+// the & must be replaced first so the entities of the other characters aren't escaped again
+const escapedDescription = "replace(replace(replace(replace(products.description, " +
+	"'&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '\"', '&quot;')"
+
+// selects the columns of the fields that have been asked for, when searching the
+// rank is always selected since the products might be sorted by it
+func selectProducts(schema *libs.FieldSetSchema, fields *libs.FieldSet, f *models.ProductFilter) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		columns := fields.Columns(productFieldColumns, schema.Fields)
+		if fields.Expands("wishlisted_by_count") {
+			columns = append(columns, "(SELECT COUNT(*) FROM user_wishlist_products "+
+				"WHERE user_wishlist_products.product_id = products.id) AS wishlisted_by_count")
+		}
+
+		if f == nil || f.Search == "" {
+			return db.Select(strings.Join(columns, ", "))
+		}
+
+		columns = append(columns, "ts_rank(products.search_vector, websearch_to_tsquery('english', @search)) AS search_rank")
+		// the description is HTML-escaped before it is highlighted, so the <mark>
+		// tags are the only markup the snippet can ever have
+		if fields.HasField("snippet") {
+			columns = append(columns, "ts_headline('english', "+escapedDescription+", "+
+				"websearch_to_tsquery('english', @search), "+
+				"'StartSel=<mark>, StopSel=</mark>, MaxFragments=2') AS snippet")
+		}
+
+		return db.Select(strings.Join(columns, ", "), sql.Named("search", f.Search))
+	}
+}
+
+func withExpansions(fields *libs.FieldSet) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if fields.Expands("categories") {
+			db = db.Preload("Categories", func(db *gorm.DB) *gorm.DB {
+				return db.Select("categories.id", "categories.name", "categories.slug")
+			})
+		}
+
+		return db
+	}
+}
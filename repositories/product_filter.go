@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"gorm.io/gorm"
+)
+
+// the only sort orders that are allowed, the sort param is looked up here and
+// never put into the query as is
+var productSorts = map[string]string{
+	"price":       "products.price ASC",
+	"-price":      "products.price DESC",
+	"created_at":  "products.created_at ASC",
+	"-created_at": "products.created_at DESC",
+	"name":        "products.name ASC",
+	"-name":       "products.name DESC",
+	"rating":      "COALESCE(ratings.average_rating, 0) ASC",
+	"-rating":     "COALESCE(ratings.average_rating, 0) DESC",
+}
+
+// a gorm scope that applies the filters of the product list, every value is
+// passed to the query as a parameter
+func filterProducts(f *models.ProductFilter) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		// the search_vector column is generated from the name and the description
+		// of the product and is indexed (see the product_search migration)
+		if f.Search != "" {
+			db = db.Where("products.search_vector @@ websearch_to_tsquery('english', ?)", f.Search)
+		}
+
+		if len(f.Categories) > 0 {
+			db = db.Where("products.id IN ("+
+				"SELECT product_categories.product_id FROM product_categories "+
+				"JOIN categories ON categories.id = product_categories.category_id "+
+				"WHERE categories.slug IN ?)", f.Categories)
+		}
+
+		if f.MinPrice != nil {
+			db = db.Where("products.price >= ?", *f.MinPrice)
+		}
+
+		if f.MaxPrice != nil {
+			db = db.Where("products.price <= ?", *f.MaxPrice)
+		}
+
+		// the discount column is nullable, a product without one has no discount
+		if f.HasDiscount != nil {
+			if *f.HasDiscount {
+				db = db.Where("COALESCE(products.discount, 0) > 0")
+			} else {
+				db = db.Where("COALESCE(products.discount, 0) = 0")
+			}
+		}
+
+		if f.InStock != nil {
+			if *f.InStock {
+				db = db.Where("products.quantity > 0")
+			} else {
+				db = db.Where("products.quantity = 0")
+			}
+		}
+
+		if f.CreatedAfter != nil {
+			db = db.Where("products.created_at >= ?", *f.CreatedAfter)
+		}
+
+		return db
+	}
+}
+
+// sorting by rating needs the ratings to be joined (see withRatings), when
+// searching without any explicit sort order the most relevant products go first
+// (the rank is selected by selectProducts)
+func sortProducts(f *models.ProductFilter) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if order, ok := productSorts[f.Sort]; ok {
+			return db.Order(order)
+		}
+
+		if f.Search != "" {
+			db = db.Order("search_rank DESC")
+		}
+
+		return db
+	}
+}
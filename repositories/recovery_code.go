@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+type RecoveryCodeRepository interface {
+	Replace(userId xid.ID, codes []models.RecoveryCode) error
+	FindUnused(userId xid.ID) ([]models.RecoveryCode, error)
+	Use(code *models.RecoveryCode) (bool, error)
+	DeleteByUser(userId xid.ID) error
+}
+
+type recoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewRecoveryCodeRepository(db *gorm.DB) RecoveryCodeRepository {
+	return &recoveryCodeRepository{db}
+}
+
+// the old codes of the user are deleted along the way, a user only ever has the
+// last set of codes
+func (rcr *recoveryCodeRepository) Replace(userId xid.ID, codes []models.RecoveryCode) error {
+	return rcr.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.RecoveryCode{}, "user_id = ?", userId).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&codes).Error
+	})
+}
+
+func (rcr *recoveryCodeRepository) FindUnused(userId xid.ID) (codes []models.RecoveryCode, err error) {
+	err = rcr.db.Find(&codes, "user_id = ? AND used_at IS NULL", userId).Error
+	return codes, err
+}
+
+func (rcr *recoveryCodeRepository) Use(code *models.RecoveryCode) (bool, error) {
+	result := rcr.db.
+		Model(&models.RecoveryCode{}).
+		Where("id = ? AND used_at IS NULL", code.ID).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+func (rcr *recoveryCodeRepository) DeleteByUser(userId xid.ID) error {
+	return rcr.db.Delete(&models.RecoveryCode{}, "user_id = ?", userId).Error
+}
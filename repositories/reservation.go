@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReservationRepository interface {
+	Create(reservation *models.Reservation) error
+	FindByIds(userId, reservationId xid.ID) (models.Reservation, error)
+	FindByIdsForUpdate(userId, reservationId xid.ID) (models.Reservation, error)
+	FindExpiredForUpdate(now time.Time, limit int) ([]models.Reservation, error)
+	UpdateStatus(reservation *models.Reservation, status string) error
+}
+
+type reservationRepository struct {
+	db *gorm.DB
+}
+
+func NewReservationRepository(db *gorm.DB) ReservationRepository {
+	return &reservationRepository{db}
+}
+
+func (rr *reservationRepository) Create(reservation *models.Reservation) error {
+	return rr.db.Create(&reservation).Error
+}
+
+func (rr *reservationRepository) FindByIds(userId, reservationId xid.ID) (reservation models.Reservation, err error) {
+	err = rr.db.Preload("Items").First(&reservation, "id = ? AND user_id = ?", reservationId, userId).Error
+	return reservation, err
+}
+
+func (rr *reservationRepository) FindByIdsForUpdate(userId, reservationId xid.ID) (reservation models.Reservation, err error) {
+	err = rr.db.
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Items").
+		First(&reservation, "id = ? AND user_id = ?", reservationId, userId).Error
+	return reservation, err
+}
+
+// expired reservations that are already locked by another transaction (e.g. the
+// owner is committing it right now or another replica's sweeper got it first)
+// are skipped instead of waited for
+func (rr *reservationRepository) FindExpiredForUpdate(now time.Time, limit int) (reservations []models.Reservation, err error) {
+	err = rr.db.
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Preload("Items").
+		Limit(limit).
+		Find(&reservations, "status = ? AND expires_at < ?", models.ReservationStatusPending, now).Error
+	return reservations, err
+}
+
+func (rr *reservationRepository) UpdateStatus(reservation *models.Reservation, status string) error {
+	return rr.db.Model(&reservation).Update("status", status).Error
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"github.com/laluardian/gin-ecommerce-api/libs"
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+type ReviewRepository interface {
+	Create(review *models.Review) error
+	FindByProduct(productId xid.ID, p *libs.Pagination) ([]models.Review, error)
+	FindByIds(productId, reviewId xid.ID) (models.Review, error)
+	FindByUser(productId, userId xid.ID) (models.Review, error)
+	Update(review *models.Review) error
+	SetHidden(review *models.Review, hidden bool) error
+	Delete(reviewId xid.ID) error
+}
+
+type reviewRepository struct {
+	db *gorm.DB
+}
+
+func NewReviewRepository(db *gorm.DB) ReviewRepository {
+	return &reviewRepository{db}
+}
+
+func (rr *reviewRepository) Create(review *models.Review) error {
+	return rr.db.Create(&review).Error
+}
+
+// only the visible reviews are listed, along with the username of their authors
+func (rr *reviewRepository) FindByProduct(productId xid.ID, p *libs.Pagination) (reviews []models.Review, err error) {
+	visible := func(db *gorm.DB) *gorm.DB {
+		return db.Where("product_id = ? AND is_hidden = ?", productId, false)
+	}
+
+	if err = rr.db.Model(&models.Review{}).Scopes(visible).Count(&p.Total).Error; err != nil {
+		return reviews, err
+	}
+
+	err = rr.db.
+		Scopes(visible, p.Paginate("id")).
+		Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "username")
+		}).
+		Find(&reviews).Error
+	if len(reviews) > 0 {
+		p.SetNextCursor(len(reviews), reviews[len(reviews)-1].ID)
+	}
+	return reviews, err
+}
+
+func (rr *reviewRepository) FindByIds(productId, reviewId xid.ID) (review models.Review, err error) {
+	err = rr.db.First(&review, "id = ? AND product_id = ?", reviewId, productId).Error
+	return review, err
+}
+
+func (rr *reviewRepository) FindByUser(productId, userId xid.ID) (review models.Review, err error) {
+	err = rr.db.First(&review, "product_id = ? AND user_id = ?", productId, userId).Error
+	return review, err
+}
+
+func (rr *reviewRepository) Update(review *models.Review) error {
+	return rr.db.Model(&review).Select("Rating", "Title", "Body").Updates(&review).Error
+}
+
+func (rr *reviewRepository) SetHidden(review *models.Review, hidden bool) error {
+	return rr.db.Model(&review).Update("is_hidden", hidden).Error
+}
+
+func (rr *reviewRepository) Delete(reviewId xid.ID) error {
+	var review models.Review
+	return rr.db.Delete(&review, "id = ?", reviewId).Error
+}
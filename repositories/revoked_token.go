@@ -0,0 +1,46 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RevokedTokenRepository interface {
+	Create(revokedToken *models.RevokedToken) error
+	Exists(jti string) (bool, error)
+	DeleteExpired() (int64, error)
+}
+
+type revokedTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRevokedTokenRepository(db *gorm.DB) RevokedTokenRepository {
+	return &revokedTokenRepository{db}
+}
+
+// revoking the same token twice is not an error
+func (rtr *revokedTokenRepository) Create(revokedToken *models.RevokedToken) error {
+	return rtr.db.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&revokedToken).Error
+}
+
+func (rtr *revokedTokenRepository) Exists(jti string) (bool, error) {
+	var revokedToken models.RevokedToken
+	err := rtr.db.Select("jti").First(&revokedToken, "jti = ?", jti).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (rtr *revokedTokenRepository) DeleteExpired() (int64, error) {
+	result := rtr.db.Delete(&models.RevokedToken{}, "expires_at < ?", time.Now())
+	return result.RowsAffected, result.Error
+}
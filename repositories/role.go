@@ -0,0 +1,40 @@
+package repositories
+
+import (
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"gorm.io/gorm"
+)
+
+type RoleRepository interface {
+	FindMany() ([]models.Role, error)
+	FindByName(name string) (models.Role, error)
+	AddToUser(role *models.Role, user *models.User) error
+	RemoveFromUser(role *models.Role, user *models.User) error
+}
+
+type roleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) RoleRepository {
+	return &roleRepository{db}
+}
+
+func (rr *roleRepository) FindMany() (roles []models.Role, err error) {
+	err = rr.db.Preload("Permissions").Order("name").Find(&roles).Error
+	return roles, err
+}
+
+func (rr *roleRepository) FindByName(name string) (role models.Role, err error) {
+	err = rr.db.Preload("Permissions").First(&role, "name = ?", name).Error
+	return role, err
+}
+
+// only the user_roles record is created, neither the role nor the user is saved
+func (rr *roleRepository) AddToUser(role *models.Role, user *models.User) error {
+	return rr.db.Model(&user).Omit("Roles.*").Association("Roles").Append(role)
+}
+
+func (rr *roleRepository) RemoveFromUser(role *models.Role, user *models.User) error {
+	return rr.db.Model(&user).Association("Roles").Delete(role)
+}
@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+type SessionRepository interface {
+	Create(session *models.Session) error
+	FindByTokenHash(tokenHash string) (models.Session, error)
+	Rotate(session *models.Session) (bool, error)
+	RevokeFamily(familyId xid.ID) error
+	RevokeByUser(userId xid.ID) error
+}
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepository{db}
+}
+
+func (sr *sessionRepository) Create(session *models.Session) error {
+	return sr.db.Create(&session).Error
+}
+
+func (sr *sessionRepository) FindByTokenHash(tokenHash string) (session models.Session, err error) {
+	err = sr.db.First(&session, "token_hash = ?", tokenHash).Error
+	return session, err
+}
+
+// marks the session as rotated, this is done with a conditional update so if the
+// same refresh token is used by two requests at the same time only one of them
+// can rotate it, false is returned to the other one
+func (sr *sessionRepository) Rotate(session *models.Session) (bool, error) {
+	result := sr.db.
+		Model(&models.Session{}).
+		Where("id = ? AND rotated_at IS NULL AND revoked_at IS NULL", session.ID).
+		Update("rotated_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+func (sr *sessionRepository) RevokeFamily(familyId xid.ID) error {
+	return sr.db.
+		Model(&models.Session{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyId).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (sr *sessionRepository) RevokeByUser(userId xid.ID) error {
+	return sr.db.
+		Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userId).
+		Update("revoked_at", time.Now()).Error
+}
@@ -1,6 +1,9 @@
 package repositories
 
 import (
+	"time"
+
+	"github.com/laluardian/gin-ecommerce-api/libs"
 	"github.com/laluardian/gin-ecommerce-api/models"
 	"github.com/rs/xid"
 	"gorm.io/gorm"
@@ -10,10 +13,17 @@ type UserRepository interface {
 	Create(user *models.User) error
 	FindByEmail(email string) (models.User, error)
 	FindById(userId xid.ID) (models.User, error)
-	FindMany() ([]models.User, error)
-	FindUserWishlist(user *models.User) ([]models.Product, error)
+	FindMany(p *libs.Pagination) ([]models.User, error)
+	FindUserWishlist(user *models.User) ([]models.WishlistProduct, error)
 	UpdateUser(user *models.User) error
 	UpdatePassword(user *models.User) error
+	FindTokenGeneration(userId xid.ID) (uint, error)
+	MarkEmailVerified(userId xid.ID) error
+	SetSuspended(user *models.User, suspended bool, reason string) error
+	SetPasswordResetRequired(user *models.User) error
+	UpdateTotp(user *models.User) error
+	UseTotpStep(userId xid.ID, step int64) (bool, error)
+	IncrementTokenGeneration(userId xid.ID) error
 	Delete(user *models.User) error
 }
 
@@ -30,31 +40,106 @@ func (ur *userRepository) Create(user *models.User) error {
 }
 
 func (ur *userRepository) FindByEmail(email string) (user models.User, err error) {
-	err = ur.db.First(&user, "email = ?", email).Error
+	err = ur.db.Preload("Roles.Permissions").First(&user, "email = ?", email).Error
 	return user, err
 }
 
 func (ur *userRepository) FindById(userId xid.ID) (user models.User, err error) {
-	err = ur.db.First(&user, "id = ?", userId).Error
+	err = ur.db.Preload("Roles.Permissions").First(&user, "id = ?", userId).Error
 	return user, err
 }
 
-func (ur *userRepository) FindMany() (users []models.User, err error) {
-	err = ur.db.Find(&users).Error
+func (ur *userRepository) FindMany(p *libs.Pagination) (users []models.User, err error) {
+	if err = ur.db.Model(&models.User{}).Count(&p.Total).Error; err != nil {
+		return users, err
+	}
+
+	err = ur.db.Scopes(p.Paginate("id")).Preload("Roles.Permissions").Find(&users).Error
+	if len(users) > 0 {
+		p.SetNextCursor(len(users), users[len(users)-1].ID)
+	}
 	return users, err
 }
 
-func (ur *userRepository) FindUserWishlist(user *models.User) (products []models.Product, err error) {
-	err = ur.db.Model(&user).Association("Wishlist").Find(&products)
-	return products, err
+func (ur *userRepository) FindUserWishlist(user *models.User) (wishlist []models.WishlistProduct, err error) {
+	err = ur.db.
+		Preload("Product").
+		Order("created_at DESC").
+		Find(&wishlist, "user_id = ?", user.ID).Error
+	return wishlist, err
 }
 
+// only the profile fields are written, everything else (the token generation,
+// the suspension, the second factor...) is changed by its own endpoints and a
+// stale copy of it must never be written back
 func (ur *userRepository) UpdateUser(user *models.User) error {
-	return ur.db.Save(&user).Error
+	return ur.db.Model(user).Select("username", "email", "email_verified_at").Updates(user).Error
 }
 
 func (ur *userRepository) UpdatePassword(user *models.User) error {
-	return ur.db.Model(&user).Update("password", user.Password).Error
+	// a new password is what a required password reset asks for
+	return ur.db.Model(&user).Updates(map[string]interface{}{
+		"password":                user.Password,
+		"password_reset_required": false,
+	}).Error
+}
+
+func (ur *userRepository) MarkEmailVerified(userId xid.ID) error {
+	return ur.db.
+		Model(&models.User{}).
+		Where("id = ?", userId).
+		Update("email_verified_at", time.Now()).Error
+}
+
+func (ur *userRepository) SetSuspended(user *models.User, suspended bool, reason string) error {
+	user.SuspendedAt = nil
+	user.SuspensionReason = ""
+	if suspended {
+		now := time.Now()
+		user.SuspendedAt = &now
+		user.SuspensionReason = reason
+	}
+
+	return ur.db.
+		Model(&user).
+		Select("SuspendedAt", "SuspensionReason").
+		Updates(&user).Error
+}
+
+func (ur *userRepository) SetPasswordResetRequired(user *models.User) error {
+	user.PasswordResetRequired = true
+	return ur.db.Model(&user).Update("password_reset_required", true).Error
+}
+
+func (ur *userRepository) UpdateTotp(user *models.User) error {
+	return ur.db.
+		Model(&user).
+		Select("TotpSecret", "TotpEnabledAt", "TotpLastStep").
+		Updates(&user).Error
+}
+
+// remembers the time step of a totp code that has just been used, the update is
+// conditional so the same code can't be used twice even by two requests at the
+// same time
+func (ur *userRepository) UseTotpStep(userId xid.ID, step int64) (bool, error) {
+	result := ur.db.
+		Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", userId, step).
+		UpdateColumn("totp_last_step", step)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (ur *userRepository) FindTokenGeneration(userId xid.ID) (uint, error) {
+	var user models.User
+	err := ur.db.Select("token_generation").First(&user, "id = ?", userId).Error
+	return user.TokenGeneration, err
+}
+
+func (ur *userRepository) IncrementTokenGeneration(userId xid.ID) error {
+	return ur.db.
+		Model(&models.User{}).
+		Where("id = ?", userId).
+		UpdateColumn("token_generation", gorm.Expr("token_generation + 1")).Error
 }
 
 func (ur *userRepository) Delete(user *models.User) error {
@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+type UserTokenRepository interface {
+	Create(userToken *models.UserToken) error
+	FindByTokenHash(purpose, tokenHash string) (models.UserToken, error)
+	Use(userToken *models.UserToken) (bool, error)
+	InvalidateByUser(userId xid.ID, purpose string) error
+}
+
+type userTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewUserTokenRepository(db *gorm.DB) UserTokenRepository {
+	return &userTokenRepository{db}
+}
+
+func (utr *userTokenRepository) Create(userToken *models.UserToken) error {
+	return utr.db.Create(&userToken).Error
+}
+
+func (utr *userTokenRepository) FindByTokenHash(purpose, tokenHash string) (userToken models.UserToken, err error) {
+	err = utr.db.First(&userToken, "purpose = ? AND token_hash = ?", purpose, tokenHash).Error
+	return userToken, err
+}
+
+// marks the token as used unless it has been used already (or it has expired),
+// the update is conditional so a token can't be used twice even by two requests
+// at the same time
+func (utr *userTokenRepository) Use(userToken *models.UserToken) (bool, error) {
+	now := time.Now()
+	result := utr.db.
+		Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", userToken.ID, now).
+		Update("used_at", now)
+	if result.RowsAffected > 0 {
+		userToken.UsedAt = &now
+	}
+	return result.RowsAffected > 0, result.Error
+}
+
+// invalidates the unused tokens of the user (e.g. when a new one is sent) by
+// marking them as used
+func (utr *userTokenRepository) InvalidateByUser(userId xid.ID, purpose string) error {
+	return utr.db.
+		Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userId, purpose).
+		Update("used_at", time.Now()).Error
+}
@@ -0,0 +1,225 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/laluardian/gin-ecommerce-api/libs"
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/laluardian/gin-ecommerce-api/repositories"
+	"github.com/rs/xid"
+	"gorm.io/gorm"
+)
+
+const defaultCacheTtl = 30 * time.Second
+
+// the revocation store decides whether an access token that is otherwise valid
+// has been revoked... a token is revoked either on its own (by its jti) or along
+// with every other token of its user when the user's token generation is bumped
+// (and of course when the user doesn't exist anymore)
+//
+// the lookups are cached in memory so most requests don't hit the database, the
+// revocations made by this instance are visible right away while the ones made
+// by other instances (if any) are picked up once the cached entries expire
+type RevocationStore interface {
+	IsRevoked(payload *libs.JwtPayload) (bool, error)
+	RevokeToken(payload *libs.JwtPayload) error
+	RevokeUser(userId xid.ID) error
+	DeleteExpired() (int64, error)
+	RunSweeper(ctx context.Context, interval time.Duration)
+}
+
+type cachedToken struct {
+	revoked   bool
+	expiresAt time.Time
+}
+
+type cachedGeneration struct {
+	generation uint
+	deleted    bool
+	expiresAt  time.Time
+}
+
+type revocationStore struct {
+	revokedTokenRepo repositories.RevokedTokenRepository
+	userRepo         repositories.UserRepository
+	sessionRepo      repositories.SessionRepository
+	cacheTtl         time.Duration
+
+	mu          sync.RWMutex
+	tokens      map[string]cachedToken
+	generations map[xid.ID]cachedGeneration
+}
+
+// the store keeps its cache in memory so a single instance of it should be
+// shared by everything that checks or revokes tokens
+func NewRevocationStore(db *gorm.DB) RevocationStore {
+	return &revocationStore{
+		revokedTokenRepo: repositories.NewRevokedTokenRepository(db),
+		userRepo:         repositories.NewUserRepository(db),
+		sessionRepo:      repositories.NewSessionRepository(db),
+		cacheTtl:         cacheTtl(),
+		tokens:           make(map[string]cachedToken),
+		generations:      make(map[xid.ID]cachedGeneration),
+	}
+}
+
+// the cache ttl can be configured with the REVOCATION_CACHE_TTL env var (e.g. "30s")
+func cacheTtl() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("REVOCATION_CACHE_TTL"))
+	if err != nil || ttl <= 0 {
+		return defaultCacheTtl
+	}
+
+	return ttl
+}
+
+func (rs *revocationStore) IsRevoked(payload *libs.JwtPayload) (bool, error) {
+	generation, err := rs.generation(payload.Sub)
+	if err != nil {
+		return false, err
+	}
+
+	if generation.deleted || payload.Gen < generation.generation {
+		return true, nil
+	}
+
+	return rs.isTokenRevoked(payload)
+}
+
+func (rs *revocationStore) generation(userId xid.ID) (cachedGeneration, error) {
+	now := time.Now()
+
+	rs.mu.RLock()
+	cached, ok := rs.generations[userId]
+	rs.mu.RUnlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached, nil
+	}
+
+	generation, err := rs.userRepo.FindTokenGeneration(userId)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return cached, err
+	}
+
+	cached = cachedGeneration{
+		generation: generation,
+		deleted:    errors.Is(err, gorm.ErrRecordNotFound),
+		expiresAt:  now.Add(rs.cacheTtl),
+	}
+
+	rs.mu.Lock()
+	rs.generations[userId] = cached
+	rs.mu.Unlock()
+
+	return cached, nil
+}
+
+func (rs *revocationStore) isTokenRevoked(payload *libs.JwtPayload) (bool, error) {
+	now := time.Now()
+
+	rs.mu.RLock()
+	cached, ok := rs.tokens[payload.Jti]
+	rs.mu.RUnlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.revoked, nil
+	}
+
+	revoked, err := rs.revokedTokenRepo.Exists(payload.Jti)
+	if err != nil {
+		return false, err
+	}
+
+	// a revoked token stays revoked so it is cached until it expires anyway
+	cached = cachedToken{revoked: revoked, expiresAt: payload.Exp.Time}
+	if !revoked && now.Add(rs.cacheTtl).Before(payload.Exp.Time) {
+		cached.expiresAt = now.Add(rs.cacheTtl)
+	}
+
+	rs.mu.Lock()
+	rs.tokens[payload.Jti] = cached
+	rs.mu.Unlock()
+
+	return revoked, nil
+}
+
+func (rs *revocationStore) RevokeToken(payload *libs.JwtPayload) error {
+	revokedToken := models.RevokedToken{
+		Jti:       payload.Jti,
+		UserID:    payload.Sub,
+		ExpiresAt: payload.Exp.Time,
+	}
+	if err := rs.revokedTokenRepo.Create(&revokedToken); err != nil {
+		return err
+	}
+
+	rs.mu.Lock()
+	rs.tokens[payload.Jti] = cachedToken{revoked: true, expiresAt: payload.Exp.Time}
+	rs.mu.Unlock()
+
+	return nil
+}
+
+// bumps the user's token generation so every access token issued so far is
+// rejected, the user's refresh tokens are revoked as well so they can't be used
+// to get new ones
+func (rs *revocationStore) RevokeUser(userId xid.ID) error {
+	if err := rs.userRepo.IncrementTokenGeneration(userId); err != nil {
+		return err
+	}
+
+	if err := rs.sessionRepo.RevokeByUser(userId); err != nil {
+		return err
+	}
+
+	rs.mu.Lock()
+	delete(rs.generations, userId)
+	rs.mu.Unlock()
+
+	return nil
+}
+
+// deletes the revoked tokens that have expired (they would be rejected anyway)
+// and prunes the expired entries of the cache
+func (rs *revocationStore) DeleteExpired() (int64, error) {
+	now := time.Now()
+
+	rs.mu.Lock()
+	for jti, cached := range rs.tokens {
+		if now.After(cached.expiresAt) {
+			delete(rs.tokens, jti)
+		}
+	}
+	for userId, cached := range rs.generations {
+		if now.After(cached.expiresAt) {
+			delete(rs.generations, userId)
+		}
+	}
+	rs.mu.Unlock()
+
+	return rs.revokedTokenRepo.DeleteExpired()
+}
+
+func (rs *revocationStore) RunSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := rs.DeleteExpired()
+			if err != nil {
+				log.Println("Error deleting expired revoked tokens:", err)
+			}
+			if deleted > 0 {
+				log.Printf("Deleted %d expired revoked token(s)\n", deleted)
+			}
+		}
+	}
+}
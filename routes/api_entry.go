@@ -1,62 +1,221 @@
 package routes
 
 import (
+	"context"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/laluardian/gin-ecommerce-api/handlers"
+	"github.com/laluardian/gin-ecommerce-api/inventory"
 	"github.com/laluardian/gin-ecommerce-api/libs"
+	"github.com/laluardian/gin-ecommerce-api/mailer"
 	"github.com/laluardian/gin-ecommerce-api/middlewares"
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/laluardian/gin-ecommerce-api/notifications"
+	"github.com/laluardian/gin-ecommerce-api/payments"
+	"github.com/laluardian/gin-ecommerce-api/ratelimit"
+	"github.com/laluardian/gin-ecommerce-api/repositories"
+	"github.com/laluardian/gin-ecommerce-api/revocation"
 )
 
 func RunApi() error {
 	dsn := os.Getenv("DATA_SOURCE_NAME")
 	db := libs.InitDB(dsn)
-	userHandler := handlers.NewUserHandler(db)
-	productHandler := handlers.NewProductHandler(db)
+
+	if err := libs.LoadJwtKeys(); err != nil {
+		return err
+	}
+
+	if err := libs.RegisterValidators(); err != nil {
+		return err
+	}
+
+	provider, err := payments.NewProvider(os.Getenv("PAYMENT_PROVIDER"))
+	if err != nil {
+		return err
+	}
+
+	mail, err := mailer.NewMailer(os.Getenv("MAILER"))
+	if err != nil {
+		return err
+	}
+
+	attemptStore, err := ratelimit.NewAttemptStore(os.Getenv("LOGIN_ATTEMPT_STORE"), db)
+	if err != nil {
+		return err
+	}
+	loginLimiter := ratelimit.NewLoginLimiter(attemptStore, ratelimit.AccountPolicyFromEnv(), ratelimit.IpPolicyFromEnv())
+
+	revocations := revocation.NewRevocationStore(db)
+	userHandler := handlers.NewUserHandler(db, revocations, mail, loginLimiter)
+	adminUserHandler := handlers.NewAdminUserHandler(db, revocations, mail)
+	mfaHandler := handlers.NewMfaHandler(db, loginLimiter)
+	notifier := notifications.NewNotifier()
+	productHandler := handlers.NewProductHandler(db, notifier)
 	addressHandler := handlers.NewAddressHandler(db)
 	categoryHandler := handlers.NewCategoryHandler(db)
+	orderHandler := handlers.NewOrderHandler(db)
+	inventoryHandler := handlers.NewInventoryHandler(db)
+	cartHandler := handlers.NewCartHandler(db)
+	paymentHandler := handlers.NewPaymentHandler(db, provider)
+	webhookHandler := handlers.NewWebhookHandler(db, provider)
+	reviewHandler := handlers.NewReviewHandler(db)
+	jwksHandler := handlers.NewJwksHandler()
+
+	// expired stock reservations are released in the background
+	go inventory.NewInventoryService(db).RunSweeper(context.Background(), time.Minute)
+
+	// and so are the revoked tokens that have expired
+	go revocations.RunSweeper(context.Background(), time.Hour)
+
+	// and the payments that were left pending or authorized by a request that
+	// never finished
+	go payments.NewSweeper(db, provider).RunSweeper(context.Background(), 5*time.Minute)
+
+	// and the sign in attempts that are too old to lock anyone out
+	go loginLimiter.RunSweeper(context.Background(), 10*time.Minute)
+
+	// the same authorization is used by every protected route
+	authorize := middlewares.JwtAuthorization(revocations, repositories.NewAuditLogRepository(db))
 
 	r := gin.Default()
+	r.Use(middlewares.ErrorHandler())
+	r.GET("/.well-known/jwks.json", jwksHandler.GetJwks)
+
 	api := r.Group("/api")
 
 	userRoutes := api.Group("/users")
 	{
 		userRoutes.POST("/signup", userHandler.SignUp)
 		userRoutes.POST("/signin", userHandler.SignIn)
+		userRoutes.POST("/signin/mfa", userHandler.SignInMfa)
+		userRoutes.POST("/token/refresh", userHandler.RefreshToken)
+		userRoutes.POST("/signout", userHandler.SignOut)
+		userRoutes.POST("/verify-email", userHandler.VerifyEmail)
+		userRoutes.POST("/password/forgot", userHandler.ForgotPassword)
+		userRoutes.POST("/password/reset", userHandler.ResetPassword)
 	}
 
-	userProtectedRoutes := api.Group("/users", middlewares.JwtAuthorization())
+	userProtectedRoutes := api.Group("/users", authorize)
 	{
-		userProtectedRoutes.GET("/", userHandler.GetMultipleUsers)
+		userProtectedRoutes.GET("/", middlewares.RequirePermission(models.PermissionUsersRead), userHandler.GetMultipleUsers)
 		userProtectedRoutes.GET("/:userId", userHandler.GetUser)
 		userProtectedRoutes.GET("/:userId/wishlist", userHandler.GetUserWishlist)
 		userProtectedRoutes.PATCH("/:userId", userHandler.UpdateUser)
 		userProtectedRoutes.PATCH("/:userId/password", userHandler.UpdatePassword)
 		userProtectedRoutes.DELETE("/:userId", userHandler.DeleteUser)
+		userProtectedRoutes.POST("/:userId/signout-all", userHandler.SignOutEverywhere)
+		userProtectedRoutes.POST("/:userId/verify-email/resend", userHandler.ResendVerificationEmail)
+	}
+
+	// the users whose email addresses are not verified can still browse the
+	// products and wishlist them, but they can't place orders, add addresses, etc.
+	requireVerifiedEmail := middlewares.RequireVerifiedEmail()
+
+	mfaRoutes := userProtectedRoutes.Group("/:userId/mfa")
+	{
+		mfaRoutes.POST("/totp", mfaHandler.EnrollTotp)
+		mfaRoutes.POST("/totp/confirm", mfaHandler.ConfirmTotp)
+		mfaRoutes.DELETE("/totp", mfaHandler.DisableTotp)
+		mfaRoutes.POST("/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+	}
+
+	adminRoutes := api.Group("/admin", authorize)
+	{
+		adminRoutes.GET("/audit-logs", middlewares.RequirePermission(models.PermissionAuditRead), adminUserHandler.GetAuditLogs)
+	}
+
+	adminUserRoutes := adminRoutes.Group("/users")
+	{
+		adminUserRoutes.GET("/", middlewares.RequirePermission(models.PermissionUsersRead), adminUserHandler.GetUsers)
+		adminUserRoutes.GET("/:userId", middlewares.RequirePermission(models.PermissionUsersRead), adminUserHandler.GetUser)
+		adminUserRoutes.POST("/:userId/suspend", middlewares.RequirePermission(models.PermissionUsersWrite), adminUserHandler.SuspendUser)
+		adminUserRoutes.POST("/:userId/unsuspend", middlewares.RequirePermission(models.PermissionUsersWrite), adminUserHandler.UnsuspendUser)
+		adminUserRoutes.POST("/:userId/password-reset", middlewares.RequirePermission(models.PermissionUsersWrite), adminUserHandler.ForcePasswordReset)
+		adminUserRoutes.POST("/:userId/roles", middlewares.RequirePermission(models.PermissionRolesWrite), adminUserHandler.GrantRole)
+		adminUserRoutes.DELETE("/:userId/roles/:role", middlewares.RequirePermission(models.PermissionRolesWrite), adminUserHandler.RevokeRole)
+		adminUserRoutes.POST("/:userId/impersonate", middlewares.RequirePermission(models.PermissionUsersImpersonate), adminUserHandler.ImpersonateUser)
 	}
 
 	addressRoutes := userProtectedRoutes.Group("/:userId/addresses")
 	{
-		addressRoutes.POST("/", addressHandler.AddAddress)
+		addressRoutes.POST("/", requireVerifiedEmail, addressHandler.AddAddress)
 		addressRoutes.GET("/", addressHandler.GetUserAddresses)
 		addressRoutes.GET("/:addressId", addressHandler.GetAddress)
-		addressRoutes.PATCH("/:addressId", addressHandler.UpdateAddress)
-		addressRoutes.DELETE("/:addressId", addressHandler.DeleteAddress)
+		addressRoutes.PATCH("/:addressId", requireVerifiedEmail, addressHandler.UpdateAddress)
+		addressRoutes.DELETE("/:addressId", requireVerifiedEmail, addressHandler.DeleteAddress)
+	}
+
+	cartRoutes := userProtectedRoutes.Group("/:userId/cart")
+	{
+		cartRoutes.GET("/", cartHandler.GetCart)
+		cartRoutes.DELETE("/", cartHandler.ClearCart)
+		cartRoutes.POST("/items", cartHandler.AddCartItem)
+		cartRoutes.PATCH("/items/:productId", cartHandler.UpdateCartItem)
+		cartRoutes.DELETE("/items/:productId", cartHandler.RemoveCartItem)
+	}
+
+	userOrderRoutes := userProtectedRoutes.Group("/:userId/orders")
+	{
+		userOrderRoutes.POST("/", requireVerifiedEmail, orderHandler.AddOrder)
+		userOrderRoutes.GET("/", orderHandler.GetUserOrders)
+		userOrderRoutes.GET("/:orderId", orderHandler.GetUserOrder)
+		userOrderRoutes.POST("/:orderId/payments", requireVerifiedEmail, paymentHandler.PayOrder)
+		userOrderRoutes.GET("/:orderId/payments", paymentHandler.GetOrderPayments)
+	}
+
+	reservationRoutes := userProtectedRoutes.Group("/:userId/reservations")
+	{
+		reservationRoutes.POST("/", requireVerifiedEmail, inventoryHandler.ReserveStock)
+		reservationRoutes.GET("/:reservationId", inventoryHandler.GetReservation)
+		reservationRoutes.DELETE("/:reservationId", inventoryHandler.ReleaseReservation)
+	}
+
+	orderRoutes := api.Group("/orders", authorize)
+	{
+		orderRoutes.GET("/", middlewares.RequirePermission(models.PermissionOrdersRead), orderHandler.GetMultipleOrders)
+		orderRoutes.GET("/:orderId", middlewares.RequirePermission(models.PermissionOrdersRead), orderHandler.GetOrder)
+	}
+
+	paymentRoutes := api.Group("/payments", authorize)
+	{
+		paymentRoutes.POST("/:paymentId/refund", middlewares.RequirePermission(models.PermissionOrdersRefund), paymentHandler.RefundPayment)
+		paymentRoutes.GET("/events", middlewares.RequirePermission(models.PermissionPaymentsManage), webhookHandler.GetPaymentEvents)
+		paymentRoutes.POST("/events/:eventId/reprocess", middlewares.RequirePermission(models.PermissionPaymentsManage), webhookHandler.ReprocessPaymentEvent)
+	}
+
+	// webhooks are called by third parties so they can't be behind the jwt
+	// authorization, their requests are verified by their signatures instead
+	webhookRoutes := api.Group("/webhooks")
+	{
+		webhookRoutes.POST("/payments", webhookHandler.ReceivePaymentEvent)
 	}
 
 	productRoutes := api.Group("/products")
 	{
 		productRoutes.GET("/", productHandler.GetMultipleProducts)
+		productRoutes.GET("/facets", productHandler.GetProductFacets)
+		productRoutes.GET("/suggest", productHandler.SuggestProducts)
 		productRoutes.GET("/:productId", productHandler.GetProduct)
+		productRoutes.GET("/:productId/reviews", reviewHandler.GetProductReviews)
 	}
 
-	productProtectedRoutes := api.Group("/products", middlewares.JwtAuthorization())
+	productProtectedRoutes := api.Group("/products", authorize)
 	{
-		productProtectedRoutes.POST("/", productHandler.AddProduct)
+		productProtectedRoutes.POST("/", middlewares.RequirePermission(models.PermissionProductsWrite), productHandler.AddProduct)
 		productProtectedRoutes.POST("/:productId/wishlist", productHandler.AddOrRemoveWishlistProduct)
-		productProtectedRoutes.PATCH("/:productId", productHandler.UpdateProduct)
-		productProtectedRoutes.DELETE("/:productId", productHandler.DeleteProduct)
+		productProtectedRoutes.PATCH("/:productId", middlewares.RequirePermission(models.PermissionProductsWrite), productHandler.UpdateProduct)
+		productProtectedRoutes.DELETE("/:productId", middlewares.RequirePermission(models.PermissionProductsWrite), productHandler.DeleteProduct)
+	}
+
+	reviewRoutes := productProtectedRoutes.Group("/:productId/reviews")
+	{
+		reviewRoutes.POST("/", requireVerifiedEmail, reviewHandler.AddReview)
+		reviewRoutes.PATCH("/:reviewId", requireVerifiedEmail, reviewHandler.UpdateReview)
+		reviewRoutes.DELETE("/:reviewId", reviewHandler.DeleteReview)
+		reviewRoutes.POST("/:reviewId/hide", middlewares.RequirePermission(models.PermissionReviewsModerate), reviewHandler.HideReview)
+		reviewRoutes.POST("/:reviewId/unhide", middlewares.RequirePermission(models.PermissionReviewsModerate), reviewHandler.UnhideReview)
 	}
 
 	categoryRoutes := api.Group("/categories")
@@ -65,11 +224,11 @@ func RunApi() error {
 		categoryRoutes.GET("/:slug", categoryHandler.GetCategory)
 	}
 
-	categoryProtectedRoutes := api.Group("/categories", middlewares.JwtAuthorization())
+	categoryProtectedRoutes := api.Group("/categories", authorize)
 	{
-		categoryProtectedRoutes.POST("/", categoryHandler.AddCategory)
-		categoryProtectedRoutes.PATCH("/:slug", categoryHandler.UpdateCategory)
-		categoryProtectedRoutes.DELETE("/:slug", categoryHandler.DeleteCategory)
+		categoryProtectedRoutes.POST("/", middlewares.RequirePermission(models.PermissionCategoriesWrite), categoryHandler.AddCategory)
+		categoryProtectedRoutes.PATCH("/:slug", middlewares.RequirePermission(models.PermissionCategoriesWrite), categoryHandler.UpdateCategory)
+		categoryProtectedRoutes.DELETE("/:slug", middlewares.RequirePermission(models.PermissionCategoriesWrite), categoryHandler.DeleteCategory)
 	}
 
 	port := os.Getenv("PORT")
@@ -0,0 +1,89 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/laluardian/gin-ecommerce-api/libs"
+	"github.com/laluardian/gin-ecommerce-api/migrations"
+	"gorm.io/gorm"
+)
+
+// the tests that need a database run against the one in TEST_DATABASE_URL, they
+// are skipped when it isn't set... every test wipes it clean, so it must never
+// be a database anyone cares about
+const DatabaseUrlEnv = "TEST_DATABASE_URL"
+
+// the key of the advisory lock every test holds while it is using the database,
+// the test packages run in parallel but they all share the same database
+const lockKey int64 = 7265636902
+
+// the tables the migrations fill themselves are left as they are
+var seededTables = map[string]bool{
+	"schema_migrations": true,
+	"permissions":       true,
+	"roles":             true,
+	"role_permissions":  true,
+}
+
+// connects to the test database, migrates it up and empties every table so the
+// test starts from scratch, the connection is closed once the test is done
+func OpenDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv(DatabaseUrlEnv)
+	if dsn == "" {
+		t.Skip(DatabaseUrlEnv + " is not set")
+	}
+
+	db := libs.OpenDB(dsn)
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey)
+		conn.Close()
+		sqlDB.Close()
+	})
+
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	var tables []string
+	err = db.Raw("SELECT tablename FROM pg_tables WHERE schemaname = current_schema()").Scan(&tables).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var truncated []string
+	for _, table := range tables {
+		if !seededTables[table] {
+			truncated = append(truncated, fmt.Sprintf("%q", table))
+		}
+	}
+	if len(truncated) > 0 {
+		if err := db.Exec("TRUNCATE " + strings.Join(truncated, ", ") + " CASCADE").Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return db
+}
@@ -0,0 +1,78 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laluardian/gin-ecommerce-api/libs"
+	"github.com/laluardian/gin-ecommerce-api/models"
+	"github.com/laluardian/gin-ecommerce-api/repositories"
+	"gorm.io/gorm"
+)
+
+// creates a user that can sign in with the given password
+func CreateUser(t *testing.T, db *gorm.DB, username, password string) models.User {
+	t.Helper()
+
+	user := models.User{
+		Username: username,
+		Email:    username + "@example.com",
+		Password: password,
+	}
+	if err := libs.HashPassword(&user.Password); err != nil {
+		t.Fatal(err)
+	}
+	if err := repositories.NewUserRepository(db).Create(&user); err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
+
+// stands in for the JwtAuthorization middleware, the request is made as the
+// given user (signed in with a second factor, so every permission counts)
+func Authenticate(user *models.User, permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(libs.JwtPayloadKey, &libs.JwtPayload{
+			Sub:         user.ID,
+			Username:    user.Username,
+			Permissions: permissions,
+			Mfa:         true,
+		})
+	}
+}
+
+// sends the body as json to the router and returns the recorded response
+func DoJSON(router http.Handler, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var payload bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&payload).Encode(body)
+	}
+
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
+
+// creates a product with the given stock
+func CreateProduct(t *testing.T, db *gorm.DB, name string, price, quantity uint32) models.Product {
+	t.Helper()
+
+	product := models.Product{
+		Name:        name,
+		Description: "A product for testing",
+		Price:       price,
+		Quantity:    quantity,
+	}
+	if err := repositories.NewProductRepository(db).Create(&product); err != nil {
+		t.Fatal(err)
+	}
+
+	return product
+}